@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"os"
+
+	"github.com/net2cn/GoNES/nes"
+)
+
+// headlessOptions What a headless run does and writes out.
+type headlessOptions struct {
+	frames  int    // 0 or less runs the whole movie.
+	input   string // Input script file.
+	movie   string // FM2 movie, played from power-on or its save state.
+	png     string // Last frame as PNG.
+	ramDump string // Internal RAM dump.
+}
+
+// runHeadless Run a ROM for a number of frames without a window, then
+// report the framebuffer hash and write requested outputs.
+func runHeadless(file string, patches []string, options headlessOptions) error {
+	cart, err := nes.NewCartridge(file, patches...)
+	if err != nil {
+		return err
+	}
+
+	var script *nes.InputScript
+	if options.input != "" {
+		f, err := os.Open(options.input)
+		if err != nil {
+			return err
+		}
+		script, err = nes.LoadInputScript(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	bus := nes.NewBus()
+	bus.InsertCartridge(cart)
+	bus.Reset()
+
+	var player *nes.MoviePlayer
+	if options.movie != "" {
+		f, err := os.Open(options.movie)
+		if err != nil {
+			return err
+		}
+		movie, err := nes.ReadMovie(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if err := movie.CheckROM(cart); err != nil {
+			fmt.Printf("Warning: %s\n", err)
+		}
+		if player, err = nes.NewMoviePlayer(bus, movie); err != nil {
+			return err
+		}
+		if options.frames <= 0 {
+			options.frames = len(movie.Frames)
+		}
+	}
+
+	for frame := 0; frame < options.frames; frame++ {
+		if script != nil {
+			script.Apply(bus, frame)
+		}
+		if player != nil {
+			if _, err := player.Frame(); err != nil {
+				return err
+			}
+		}
+		bus.RunFrame()
+	}
+
+	// Hash palette indices so that the result doesn't depend on the palette.
+	fmt.Printf("frames %d framebuffer sha1 %x\n", options.frames, sha1.Sum(bus.PPU.GetFrame()))
+
+	if options.png != "" {
+		f, err := os.Create(options.png)
+		if err != nil {
+			return err
+		}
+		err = png.Encode(f, bus.PPU.GetScreen())
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.ramDump != "" {
+		if err := ioutil.WriteFile(options.ramDump, bus.RAM(), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"image"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"runtime/pprof"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/net2cn/GoNES/nes"
@@ -23,6 +28,27 @@ var fontPath string = "./assets/UbuntuMono-R.ttf" // Man, do not use a variable-
 var fontSize int = 15
 var windowWidth, windowHeight int32 = 680, 480
 
+// Audio.
+var audioSampleRate int32 = 44100
+var audioBufferSize uint16 = 1024     // Device buffer size in samples.
+var audioLatency uint32 = 2048        // Samples we try to keep queued in the device.
+var audioMaxRateDelta float64 = 0.005 // Max sample rate adjustment of dynamic rate control.
+var audioMaxFrames int = 4            // Max frames emulated per update when catching up.
+
+// Battery-backed saves are flushed this often, and on exit.
+var saveInterval time.Duration = 5 * time.Second
+
+// Save state slots are kept here, named after the game.
+var stateDir string = "./states"
+
+// Rewind history, hold backspace to run backwards. Depth 0 disables it.
+var rewindInterval int = 2      // Frames between snapshots.
+var rewindDepth int = 600       // Snapshots kept.
+var rewindBudget int = 64 << 20 // Bytes of memory history may use.
+
+// NTSC NES runs at 60.0988 frames per second.
+var frameRate float64 = 60.0988
+
 // Timer.
 var startTime time.Time = time.Now()
 var endTime time.Time = time.Now()
@@ -32,10 +58,28 @@ type debugger struct {
 	bus  *nes.Bus
 	cart *nes.Cartridge
 
+	title        string
 	emulationRun bool
 	residualTime int64
+	lastSave     time.Time
+
+	audioDevice  sdl.AudioDeviceID
+	audioEnabled bool
+	audioRate    float64
+	audioSamples []float32
+	audioBytes   []byte
+	volume       float32
+	muted        bool
 
 	selectedPalette uint8
+	stateSlot       int
+	rewinder        *nes.Rewinder
+
+	moviePlayer   *nes.MoviePlayer
+	movieRecorder *nes.MovieRecorder
+	moviePath     string      // Where recorded movie is written on exit.
+	movieCommands uint8       // Resets to record with next frame.
+	slotFrames    map[int]int // Movie frame each slot was saved at while recording.
 
 	mapASM    map[uint16]string
 	mapKeys   []int
@@ -44,6 +88,8 @@ type debugger struct {
 	window   *sdl.Window
 	surface  *sdl.Surface
 	buffer   *sdl.Surface
+	screen   *sdl.Surface    // NES screen converted for SDL.
+	patterns [2]*sdl.Surface // Pattern tables converted for SDL.
 	renderer *sdl.Renderer
 	font     *ttf.Font
 }
@@ -74,6 +120,22 @@ func (debug *debugger) drawSprite(x int, y int, sprite *sdl.Surface) {
 	sprite.Blit(nil, debug.buffer, &sdl.Rect{X: int32(x), Y: int32(y)})
 }
 
+// Copy an RGBA image from NES into an SDL surface of the same size.
+func (debug *debugger) convertImage(src *image.RGBA, dst *sdl.Surface) *sdl.Surface {
+	if err := dst.Lock(); err != nil {
+		fmt.Printf("Failed to lock surface: %s\n", err)
+		return dst
+	}
+	pixels := dst.Pixels()
+	pitch := int(dst.Pitch)
+	width := src.Rect.Dx() * 4
+	for y := 0; y < src.Rect.Dy(); y++ {
+		copy(pixels[y*pitch:y*pitch+width], src.Pix[y*src.Stride:y*src.Stride+width])
+	}
+	dst.Unlock()
+	return dst
+}
+
 // Draw a part of sprite.
 func (debug *debugger) drawPartialSprite(dstX int, dstY int, sprite *sdl.Surface, srcX int, srcY int, w int, h int) {
 	dstRect := sdl.Rect{X: int32(dstX), Y: int32(dstY), W: int32(w), H: int32(h)}
@@ -107,7 +169,11 @@ func (debug *debugger) drawCPU(x int, y int) {
 	debug.drawString(x+144, y, "U", debug.getFlagColor(debug.bus.CPU.Status&(1<<5)))
 	debug.drawString(x+160, y, "O", debug.getFlagColor(debug.bus.CPU.Status&(1<<6)))
 	debug.drawString(x+178, y, "N", debug.getFlagColor(debug.bus.CPU.Status&(1<<7)))
-	debug.drawString(x, y+10, "PC: $"+nes.ConvertToHex(debug.bus.CPU.PC, 4), color)
+	if debug.bus.CPU.Halted() {
+		debug.drawString(x, y+10, "PC: $"+nes.ConvertToHex(debug.bus.CPU.PC, 4)+" HALTED", &sdl.Color{R: 255, G: 0, B: 0, A: 0})
+	} else {
+		debug.drawString(x, y+10, "PC: $"+nes.ConvertToHex(debug.bus.CPU.PC, 4), color)
+	}
 	// It looks really sucks.
 	debug.drawString(x, y+20, "A:  $"+nes.ConvertToHex(uint16(debug.bus.CPU.A), 2)+" ["+nes.ConvertUint8ToString(debug.bus.CPU.A)+"]", color)
 	debug.drawString(x, y+30, "X:  $"+nes.ConvertToHex(uint16(debug.bus.CPU.X), 2)+" ["+nes.ConvertUint8ToString(debug.bus.CPU.X)+"]", color)
@@ -180,12 +246,201 @@ func (debug *debugger) getFlagColor(flag uint8) *sdl.Color {
 	return &sdl.Color{R: 255, G: 0, B: 0, A: 0}
 }
 
+// Emulate a frame, with movie input and rewind history.
+func (debug *debugger) runFrame() {
+	debug.movieFrame()
+	debug.bus.RunFrame()
+	debug.recordRewind()
+}
+
+// Play or record movie input of the frame about to be emulated.
+func (debug *debugger) movieFrame() {
+	if debug.moviePlayer != nil {
+		playing, err := debug.moviePlayer.Frame()
+		if err != nil {
+			fmt.Printf("Failed to play movie: %s\n", err)
+		}
+		if !playing || err != nil {
+			fmt.Printf("Movie finished after %d frames\n", debug.moviePlayer.Position())
+			debug.moviePlayer = nil
+		}
+	} else if debug.movieRecorder != nil {
+		if err := debug.movieRecorder.Frame(debug.movieCommands); err != nil {
+			fmt.Printf("Failed to record movie: %s\n", err)
+		}
+		debug.movieCommands = 0
+	}
+}
+
+// Start playing a movie file.
+func (debug *debugger) playMovie(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	movie, err := nes.ReadMovie(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := movie.CheckROM(debug.cart); err != nil {
+		fmt.Printf("Warning: %s\n", err)
+	}
+
+	debug.moviePlayer, err = nes.NewMoviePlayer(debug.bus, movie)
+	return err
+}
+
+// Start recording a movie, from power-on or from current state.
+func (debug *debugger) recordMovie(path string, fromState bool) error {
+	var err error
+	debug.movieRecorder, err = nes.NewMovieRecorder(debug.bus, fromState)
+	if err != nil {
+		return err
+	}
+	debug.moviePath = path
+	debug.slotFrames = map[int]int{}
+	return nil
+}
+
+// Write recorded movie.
+func (debug *debugger) saveMovie() error {
+	f, err := os.Create(debug.moviePath)
+	if err != nil {
+		return err
+	}
+	err = debug.movieRecorder.Movie().Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Let rewinder snapshot the frame just emulated.
+func (debug *debugger) recordRewind() {
+	if debug.rewinder == nil {
+		return
+	}
+	if err := debug.rewinder.Frame(); err != nil {
+		fmt.Printf("Failed to record rewind snapshot: %s\n", err)
+	}
+}
+
+// Path of the file backing current save state slot.
+func (debug *debugger) statePath() string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, debug.cart.Title())
+	return filepath.Join(stateDir, fmt.Sprintf("%s.%d.state", name, debug.stateSlot))
+}
+
+// Save machine state to current slot.
+func (debug *debugger) saveState() error {
+	if err := os.MkdirAll(stateDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a failed save doesn't eat the slot.
+	path := debug.statePath()
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = debug.bus.SaveState(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Load machine state from current slot.
+func (debug *debugger) loadState() error {
+	f, err := os.Open(debug.statePath())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return debug.bus.LoadState(bufio.NewReader(f))
+}
+
+// Open an audio device and let APU output at its rate.
+func (debug *debugger) openAudio() error {
+	var err error
+	desired := sdl.AudioSpec{
+		Freq:     audioSampleRate,
+		Format:   sdl.AUDIO_F32LSB,
+		Channels: 1,
+		Samples:  audioBufferSize,
+	}
+	obtained := sdl.AudioSpec{}
+
+	debug.audioDevice, err = sdl.OpenAudioDevice("", false, &desired, &obtained, sdl.AUDIO_ALLOW_FREQUENCY_CHANGE)
+	if err != nil {
+		return err
+	}
+
+	debug.audioRate = float64(obtained.Freq)
+	debug.audioSamples = make([]float32, obtained.Freq)
+	debug.audioBytes = make([]byte, 0, len(debug.audioSamples)*4)
+	debug.bus.APU.SetSampleRate(debug.audioRate)
+
+	// Unpause device, it plays silence until we queue something.
+	sdl.PauseAudioDevice(debug.audioDevice, false)
+	return nil
+}
+
+// Return how many samples are still waiting in audio device.
+func (debug *debugger) queuedAudio() uint32 {
+	return sdl.GetQueuedAudioSize(debug.audioDevice) / 4
+}
+
+// Move samples from APU to audio device.
+func (debug *debugger) queueAudio() {
+	n := debug.bus.APU.ReadSamples(debug.audioSamples)
+
+	volume := debug.volume
+	if debug.muted {
+		volume = 0
+	}
+
+	debug.audioBytes = debug.audioBytes[:0]
+	for _, sample := range debug.audioSamples[:n] {
+		bits := math.Float32bits(sample * volume)
+		debug.audioBytes = append(debug.audioBytes, byte(bits), byte(bits>>8), byte(bits>>16), byte(bits>>24))
+	}
+
+	if err := sdl.QueueAudio(debug.audioDevice, debug.audioBytes); err != nil {
+		fmt.Printf("Failed to queue audio: %s\n", err)
+	}
+}
+
+// Dynamic rate control, nudge APU sample rate a little so that the queued audio
+// hovers around our latency target instead of underrunning (crackles) or piling
+// up (drift).
+func (debug *debugger) adjustAudioRate(queued uint32) {
+	fill := float64(queued) / float64(audioLatency)
+	ratio := 1 + audioMaxRateDelta*(1-fill)
+	ratio = math.Max(1-audioMaxRateDelta, math.Min(1+audioMaxRateDelta, ratio))
+	debug.bus.APU.SetSampleRate(debug.audioRate * ratio)
+}
+
 // Construct our debug.
-func (debug *debugger) Construct(filePath string, width int32, height int32) error {
+func (debug *debugger) Construct(filePath string, patches []string, width int32, height int32) error {
 	var err error
 
 	// Init sdl2
-	if err = sdl.Init(sdl.INIT_VIDEO); err != nil {
+	if err = sdl.Init(sdl.INIT_VIDEO | sdl.INIT_AUDIO); err != nil {
 		fmt.Printf("Failed to init sdl2: %s\n", err)
 		panic(err)
 	}
@@ -220,6 +475,18 @@ func (debug *debugger) Construct(filePath string, width int32, height int32) err
 		fmt.Printf("Failed to create buffer: %s\n", err)
 	}
 
+	// Create surfaces NES images are converted into, byte order matches image.RGBA.
+	if debug.screen, err = sdl.CreateRGBSurfaceWithFormat(0, nes.ScreenWidth, nes.ScreenHeight, 32, sdl.PIXELFORMAT_RGBA32); err != nil {
+		fmt.Printf("Failed to create screen: %s\n", err)
+		panic(err)
+	}
+	for i := range debug.patterns {
+		if debug.patterns[i], err = sdl.CreateRGBSurfaceWithFormat(0, 128, 128, 32, sdl.PIXELFORMAT_RGBA32); err != nil {
+			fmt.Printf("Failed to create pattern table %d: %s\n", i, err)
+			panic(err)
+		}
+	}
+
 	// Create renderer
 	debug.renderer, err = sdl.CreateRenderer(debug.window, -1, sdl.RENDERER_ACCELERATED)
 	if err != nil {
@@ -230,13 +497,30 @@ func (debug *debugger) Construct(filePath string, width int32, height int32) err
 	// Init our NES.
 	debug.bus = nes.NewBus()
 
+	// Init audio, we can still run without it.
+	debug.volume = 0.8
+	debug.muted = false
+	if err = debug.openAudio(); err != nil {
+		fmt.Printf("Failed to open audio device: %s\n", err)
+		debug.audioEnabled = false
+	} else {
+		debug.audioEnabled = true
+	}
+
 	// Load cartridge
-	debug.cart, err = nes.NewCartridge(filePath)
+	debug.cart, err = nes.NewCartridge(filePath, patches...)
 	if err != nil {
 		return err
 	}
+	for _, patch := range debug.cart.Patches() {
+		fmt.Printf("Applied %s patch %s\n", patch.Format, patch.Name)
+	}
+
+	debug.title = windowTitle + " - " + debug.cart.Title()
+	debug.window.SetTitle(debug.title)
 
 	debug.emulationRun = false
+	debug.lastSave = time.Now()
 	debug.residualTime = 0.0
 
 	// Insert cartridge
@@ -256,6 +540,10 @@ func (debug *debugger) Construct(filePath string, width int32, height int32) err
 
 	debug.bus.Reset()
 
+	if rewindDepth > 0 {
+		debug.rewinder = nes.NewRewinder(debug.bus, rewindInterval, rewindDepth, rewindBudget)
+	}
+
 	// Get inputLock ready for user input.
 	debug.inputLock = false
 
@@ -351,7 +639,11 @@ func (debug *debugger) Update(elapsedTime int64) bool {
 				case sdl.K_SPACE:
 					debug.emulationRun = !debug.emulationRun
 				case sdl.K_r:
-					debug.bus.CPU.Reset()
+					if debug.movieRecorder != nil {
+						debug.movieCommands |= nes.MovieSoftReset
+					} else {
+						debug.bus.CPU.Reset()
+					}
 				case sdl.K_p:
 					debug.selectedPalette++
 					debug.selectedPalette &= 0x07
@@ -359,7 +651,46 @@ func (debug *debugger) Update(elapsedTime int64) bool {
 					if !nes.IsPathExists("./debug") {
 						os.Mkdir("debug", os.ModePerm)
 					}
-					img.SavePNG(debug.bus.PPU.GetScreen(), "./debug/sprite.png")
+					img.SavePNG(debug.convertImage(debug.bus.PPU.GetScreen(), debug.screen), "./debug/sprite.png")
+				// Save states.
+				case sdl.K_0, sdl.K_1, sdl.K_2, sdl.K_3, sdl.K_4, sdl.K_5, sdl.K_6, sdl.K_7, sdl.K_8, sdl.K_9:
+					debug.stateSlot = int(t.Keysym.Sym - sdl.K_0)
+					fmt.Printf("Selected state slot %d\n", debug.stateSlot)
+				case sdl.K_F5:
+					if err := debug.saveState(); err != nil {
+						fmt.Printf("Failed to save state: %s\n", err)
+					} else {
+						fmt.Printf("Saved state slot %d\n", debug.stateSlot)
+						if debug.movieRecorder != nil {
+							debug.slotFrames[debug.stateSlot] = debug.movieRecorder.Position()
+						}
+					}
+				case sdl.K_F7:
+					// A state from outside the recording would break the movie.
+					frame, ok := debug.slotFrames[debug.stateSlot]
+					if debug.movieRecorder != nil && !ok {
+						fmt.Printf("State slot %d wasn't saved during this recording\n", debug.stateSlot)
+						break
+					}
+					if err := debug.loadState(); err != nil {
+						fmt.Printf("Failed to load state: %s\n", err)
+						break
+					}
+					fmt.Printf("Loaded state slot %d\n", debug.stateSlot)
+					if debug.movieRecorder != nil {
+						debug.movieRecorder.Rerecord(frame)
+					}
+					if debug.moviePlayer != nil {
+						fmt.Printf("Movie stopped\n")
+						debug.moviePlayer = nil
+					}
+				// Audio.
+				case sdl.K_m:
+					debug.muted = !debug.muted
+				case sdl.K_MINUS:
+					debug.volume = float32(math.Max(0, float64(debug.volume)-0.1))
+				case sdl.K_EQUALS:
+					debug.volume = float32(math.Min(1, float64(debug.volume)+0.1))
 				}
 			}
 
@@ -379,16 +710,41 @@ func (debug *debugger) Update(elapsedTime int64) bool {
 	}
 
 	// Check if we have reach the end of a frame
-	if debug.emulationRun {
+	// Movies can't follow time going backwards.
+	rewinding := debug.rewinder != nil && debug.moviePlayer == nil && debug.movieRecorder == nil &&
+		keyState[sdl.SCANCODE_BACKSPACE] != 0
+
+	if debug.emulationRun && rewinding {
+		// Step back one frame per frame, audio just runs dry meanwhile.
 		if debug.residualTime > 0 {
 			debug.residualTime -= elapsedTime
 		} else {
-			debug.residualTime += 1000000/60 - elapsedTime
-			// Golang's do while.
-			for done := true; done; done = debug.bus.PPU.FrameComplete != true {
-				debug.bus.Clock()
+			debug.residualTime += int64(1000000/frameRate) - elapsedTime
+			if _, err := debug.rewinder.Rewind(); err != nil {
+				fmt.Printf("Failed to rewind: %s\n", err)
+			}
+		}
+	} else if debug.emulationRun {
+		if debug.audioEnabled {
+			// Audio device consumes samples at exactly its own rate, so keeping
+			// it fed paces emulation for us.
+			frames := 0
+			for queued := debug.queuedAudio(); queued < audioLatency && frames < audioMaxFrames; queued = debug.queuedAudio() {
+				debug.adjustAudioRate(queued)
+				debug.runFrame()
+				debug.queueAudio()
+				frames++
 			}
-			debug.bus.PPU.FrameComplete = false
+
+			// Nothing to do yet, don't spin.
+			if frames == 0 {
+				sdl.Delay(1)
+			}
+		} else if debug.residualTime > 0 {
+			debug.residualTime -= elapsedTime
+		} else {
+			debug.residualTime += int64(1000000/frameRate) - elapsedTime
+			debug.runFrame()
 		}
 	}
 
@@ -396,13 +752,13 @@ func (debug *debugger) Update(elapsedTime int64) bool {
 	// Always remember to draw on buffer.
 
 	// Draw screen, sprites.
-	debug.drawSprite(0, 0, debug.bus.PPU.GetScreen())
+	debug.drawSprite(0, 0, debug.convertImage(debug.bus.PPU.GetScreen(), debug.screen))
 	// Quick hack to render background tiles
 	// nameTable := debug.bus.PPU.GetPatternTable(0, debug.selectedPalette)
 	// debug.drawNameTable(0, 0, nameTable)
 
-	debug.drawSprite(416, 349, debug.bus.PPU.GetPatternTable(0, debug.selectedPalette))
-	debug.drawSprite(416+132, 349, debug.bus.PPU.GetPatternTable(1, debug.selectedPalette))
+	debug.drawSprite(416, 349, debug.convertImage(debug.bus.PPU.GetPatternTable(0, debug.selectedPalette), debug.patterns[0]))
+	debug.drawSprite(416+132, 349, debug.convertImage(debug.bus.PPU.GetPatternTable(1, debug.selectedPalette), debug.patterns[1]))
 
 	// Draw selected palettes border.
 	switchSize := 6
@@ -453,6 +809,17 @@ func (debug *debugger) Update(elapsedTime int64) bool {
 	debug.drawString(2, 456, "D - Dump screen", &sdl.Color{R: 0, G: 255, B: 0, A: 0})
 	debug.drawString(2, 466, "P - Change palette", &sdl.Color{R: 0, G: 255, B: 0, A: 0})
 
+	debug.drawString(200, 406, "Audio", &sdl.Color{R: 255, G: 255, B: 0, A: 0})
+	debug.drawString(200, 416, "M - Mute", &sdl.Color{R: 0, G: 255, B: 0, A: 0})
+	debug.drawString(200, 426, "- / = - Volume", &sdl.Color{R: 0, G: 255, B: 0, A: 0})
+	if !debug.audioEnabled {
+		debug.drawString(200, 446, "No audio device", &sdl.Color{R: 255, G: 0, B: 0, A: 0})
+	} else if debug.muted {
+		debug.drawString(200, 446, "Volume: Muted", &sdl.Color{R: 255, G: 0, B: 0, A: 0})
+	} else {
+		debug.drawString(200, 446, "Volume: "+strconv.Itoa(int(math.Round(float64(debug.volume)*100)))+"%", &sdl.Color{R: 0, G: 255, B: 0, A: 0})
+	}
+
 	// Swap buffer and present our rendered content.
 	debug.window.UpdateSurface()
 	debug.buffer.Blit(nil, debug.surface, nil)
@@ -464,6 +831,33 @@ func (debug *debugger) Update(elapsedTime int64) bool {
 	return true
 }
 
+// Write battery-backed RAM to disk.
+func (debug *debugger) flushSave() {
+	debug.lastSave = time.Now()
+	if err := debug.cart.Save(); err != nil {
+		fmt.Printf("Failed to write save file: %s\n", err)
+	}
+}
+
+// Destruct Release resources held by our debugger.
+func (debug *debugger) Destruct() {
+	debug.flushSave()
+	if debug.movieRecorder != nil {
+		if err := debug.saveMovie(); err != nil {
+			fmt.Printf("Failed to save movie: %s\n", err)
+		} else {
+			fmt.Printf("Saved movie %s\n", debug.moviePath)
+		}
+	}
+	if debug.audioEnabled {
+		sdl.CloseAudioDevice(debug.audioDevice)
+	}
+	debug.screen.Free()
+	for _, pattern := range debug.patterns {
+		pattern.Free()
+	}
+}
+
 func (debug *debugger) Start() {
 	var passedTime int64 = 0
 	var passedFrame int64 = 0
@@ -478,11 +872,72 @@ func (debug *debugger) Start() {
 		passedTime += elapsedTime
 		passedFrame++
 		if passedTime >= 1000000 {
-			debug.window.SetTitle(windowTitle + " FPS: " + strconv.Itoa(int(1000000/(passedTime/passedFrame))))
+			debug.window.SetTitle(debug.title + " FPS: " + strconv.Itoa(int(1000000/(passedTime/passedFrame))))
 			passedTime = 0
 			passedFrame = 0
 		}
+
+		if time.Since(debug.lastSave) >= saveInterval {
+			debug.flushSave()
+		}
+	}
+}
+
+// Flag collecting every -patch given.
+type patchList []string
+
+func (patches *patchList) String() string {
+	return strings.Join(*patches, ",")
+}
+
+func (patches *patchList) Set(value string) error {
+	*patches = append(*patches, value)
+	return nil
+}
+
+// stateCommand Handle "GoNES state <subcommand>".
+func stateCommand(args []string) error {
+	if len(args) != 2 || args[0] != "inspect" {
+		return fmt.Errorf("usage: GoNES state inspect [state_file]")
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nes.InspectState(bufio.NewReader(f), os.Stdout)
+}
+
+// runTrace Run a ROM headless and write its CPU trace.
+func runTrace(file string, patches []string, output string, pc string, steps int) error {
+	cart, err := nes.NewCartridge(file, patches...)
+	if err != nil {
+		return err
+	}
+
+	bus := nes.NewBus()
+	bus.InsertCartridge(cart)
+	bus.Reset()
+	bus.StepInstruction()
+
+	if pc != "" {
+		addr, err := strconv.ParseUint(pc, 16, 16)
+		if err != nil {
+			return fmt.Errorf("invalid start address %q: %w", pc, err)
+		}
+		bus.CPU.PC = uint16(addr)
 	}
+
+	w := os.Stdout
+	if output != "-" {
+		w, err = os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	return bus.RunTrace(w, steps)
 }
 
 func main() {
@@ -492,17 +947,78 @@ func main() {
 	fmt.Println("With programming we have god's hand.")
 
 	// Read flags
-	var file = flag.String("file", "", "NES ROM file")
+	var file = flag.String("file", "", "NES ROM file, can be packed in .zip or .gz")
 	var cpuprofile = flag.String("cpuprofile", "", "Write cpu profile to file")
-
-	flag.Parse()
+	var gamedb = flag.String("gamedb", "", "Game database file, text or nes20db.xml")
+	var patches patchList
+	flag.Var(&patches, "patch", "IPS, UPS or BPS patch applied to ROM, can be given more than once")
+	var trace = flag.String("trace", "", "Write a nestest.log style CPU trace to file and exit, \"-\" for stdout")
+	var tracePC = flag.String("pc", "", "Start tracing at this hex address instead of the reset vector, e.g. C000 for nestest")
+	var traceSteps = flag.Int("steps", 8991, "Number of instructions to trace")
+	var headless = flag.Bool("headless", false, "Run without a window, use with -frames")
+	var frames = flag.Int("frames", 600, "Number of frames to run headless, a -movie runs to its end unless given")
+	var input = flag.String("input", "", "Input script fed to controllers when headless")
+	var pngPath = flag.String("png", "", "Write last frame to PNG file when headless")
+	var ramDump = flag.String("ram", "", "Write internal RAM to file when headless")
+	var moviePath = flag.String("movie", "", "Play FM2 movie, input from keyboard is ignored until it ends")
+	var recordPath = flag.String("record", "", "Record FM2 movie to file from power-on")
+	var recordSlot = flag.Int("record-slot", -1, "Record from this save state slot instead of power-on")
+	flag.IntVar(&rewindInterval, "rewind-interval", rewindInterval, "Frames between rewind snapshots")
+	flag.IntVar(&rewindDepth, "rewind-depth", rewindDepth, "Rewind snapshots kept, 0 disables rewind")
+	var roundTrip = flag.Bool("roundtrip", false, "Reload the second verify run from save states every frame")
+	var hashes = flag.String("hashes", "", "Write per-frame hash log of a verify run, to compare with another build")
+	var against = flag.String("against", "", "Compare a verify run with the hash log of another build")
+	var traceFrame = flag.Int("trace-frame", -1, "Log this frame of a verify run instruction by instruction")
+	var rewindMB = flag.Int("rewind-budget", rewindBudget>>20, "Memory in MB rewind history may use")
+
+	// "GoNES run ..." is the same as "GoNES ...".
+	args := os.Args[1:]
+	verify := false
+	if len(args) > 0 && args[0] == "run" {
+		args = args[1:]
+	} else if len(args) > 0 && args[0] == "verify" {
+		verify = true
+		args = args[1:]
+	} else if len(args) > 0 && args[0] == "state" {
+		if err := stateCommand(args[1:]); err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	flag.CommandLine.Parse(args)
 
 	// Handle flags
+	rewindBudget = *rewindMB << 20
+	if *file == "" && flag.NArg() > 0 {
+		*file = flag.Arg(0)
+	}
 	if *file == "" {
 		fmt.Println("Please specify a NES ROM file.")
 		os.Exit(1)
 	}
 
+	if *gamedb == "" {
+		// NES 2.0 database next to the executable is loaded if there is one.
+		if exe, err := os.Executable(); err == nil {
+			file := filepath.Join(filepath.Dir(exe), "nes20db.xml")
+			if _, err := os.Stat(file); err == nil {
+				*gamedb = file
+			}
+		}
+	}
+	if *gamedb != "" {
+		f, err := os.Open(*gamedb)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = nes.LoadGameDatabase(f)
+		f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -512,13 +1028,81 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
+	if *trace != "" {
+		err := runTrace(*file, patches, *trace, *tracePC, *traceSteps)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Movies run to their end unless told otherwise.
+	framesSet := false
+	flag.Visit(func(f *flag.Flag) { framesSet = framesSet || f.Name == "frames" })
+	if *moviePath != "" && !framesSet {
+		*frames = 0
+	}
+
+	if verify {
+		err := runVerify(*file, patches, verifyOptions{
+			movie:      *moviePath,
+			frames:     *frames,
+			roundTrip:  *roundTrip,
+			hashes:     *hashes,
+			against:    *against,
+			traceFrame: *traceFrame,
+		})
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *headless {
+		err := runHeadless(*file, patches, headlessOptions{
+			frames:  *frames,
+			input:   *input,
+			movie:   *moviePath,
+			png:     *pngPath,
+			ramDump: *ramDump,
+		})
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Construct a debugger instance.
 	debug := debugger{}
-	err := debug.Construct(*file, windowWidth, windowHeight)
+	err := debug.Construct(*file, patches, windowWidth, windowHeight)
 	if err != nil {
-		return
+		fmt.Printf("%s\n", err)
+		os.Exit(1)
+	}
+
+	if *moviePath != "" {
+		err = debug.playMovie(*moviePath)
+	} else if *recordPath != "" {
+		if *recordSlot >= 0 {
+			debug.stateSlot = *recordSlot
+			if err = debug.loadState(); err != nil {
+				err = fmt.Errorf("loading state slot %d: %w", *recordSlot, err)
+			}
+		}
+		if err == nil {
+			err = debug.recordMovie(*recordPath, *recordSlot >= 0)
+		}
+	}
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		debug.Destruct()
+		os.Exit(1)
 	}
 
 	// Start debugger.
 	debug.Start()
+	debug.Destruct()
 }
@@ -0,0 +1,809 @@
+package nes
+
+import "math"
+
+// NTSC CPU clock rate, the APU is clocked at the same rate.
+const cpuClockRate = 1789773.0
+
+// Default rate of the mixed sample stream.
+const defaultSampleRate = 44100.0
+
+var lengthTable = [32]uint8{
+	10, 254, 20, 2, 40, 4, 80, 6, 160, 8, 60, 10, 14, 12, 26, 14,
+	12, 16, 24, 18, 48, 20, 96, 22, 192, 24, 72, 26, 16, 28, 32, 30,
+}
+
+var dutyTable = [4][8]uint8{
+	{0, 1, 0, 0, 0, 0, 0, 0},
+	{0, 1, 1, 0, 0, 0, 0, 0},
+	{0, 1, 1, 1, 1, 0, 0, 0},
+	{1, 0, 0, 1, 1, 1, 1, 1},
+}
+
+var triangleTable = [32]uint8{
+	15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0,
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+}
+
+// Periods are in APU cycles for noise and CPU cycles for DMC (NTSC).
+var noiseTable = [16]uint16{
+	2, 4, 8, 16, 32, 48, 64, 80, 101, 127, 190, 254, 381, 508, 1017, 2034,
+}
+
+var dmcTable = [16]uint16{
+	428, 380, 340, 320, 286, 254, 226, 214, 190, 160, 142, 128, 106, 84, 72, 54,
+}
+
+// Non-linear mixer lookup tables, see http://wiki.nesdev.com/w/index.php/APU_Mixer
+var pulseMixTable [31]float32
+var tndMixTable [203]float32
+
+func init() {
+	for i := 1; i < len(pulseMixTable); i++ {
+		pulseMixTable[i] = float32(95.52 / (8128.0/float64(i) + 100))
+	}
+	for i := 1; i < len(tndMixTable); i++ {
+		tndMixTable[i] = float32(163.67 / (24329.0/float64(i) + 100))
+	}
+}
+
+// APU Nintendo 2A03 APU struct
+type APU struct {
+	bus *Bus
+
+	pulse1   apuPulse
+	pulse2   apuPulse
+	triangle apuTriangle
+	noise    apuNoise
+	dmc      apuDMC
+
+	clockCounter uint64 // CPU cycles since reset
+	frameCounter uint16 // CPU cycles into the current frame sequence
+	frameMode    bool   // false for 4-step mode, true for 5-step mode
+	frameInhibit bool   // Frame IRQ inhibit flag
+	frameIRQ     bool
+
+	sampleRate      float64
+	sampleCounter   float64
+	cyclesPerSample float64
+	filters         []apuFilter
+	samples         []float32
+}
+
+// ConnectAPU Initialize an APU and connect it to the bus.
+func ConnectAPU(bus *Bus) *APU {
+	apu := APU{}
+	apu.bus = bus
+	apu.pulse1.channel = 1
+	apu.pulse2.channel = 2
+	apu.noise.shiftRegister = 1
+	// Rate registers power up as 0, the shortest noise and slowest DMC period.
+	apu.noise.timerPeriod = noiseTable[0]
+	apu.dmc.tickPeriod = dmcTable[0]
+	apu.SetSampleRate(defaultSampleRate)
+	return &apu
+}
+
+// Reset Reset APU.
+func (apu *APU) Reset() {
+	apu.CPUWrite(0x4015, 0x00)
+	apu.clockCounter = 0
+	apu.frameCounter = 0
+	apu.frameIRQ = false
+	apu.dmc.irqFlag = false
+	apu.updateIRQ()
+	apu.sampleCounter = 0
+	apu.samples = apu.samples[:0]
+}
+
+// SetSampleRate Set the rate of the mixed sample stream in Hz.
+func (apu *APU) SetSampleRate(rate float64) {
+	apu.sampleRate = rate
+	apu.cyclesPerSample = cpuClockRate / rate
+
+	// The NES output stage has two high-pass filters and a low-pass filter.
+	filters := []apuFilter{
+		newHighPassFilter(rate, 90),
+		newHighPassFilter(rate, 440),
+		newLowPassFilter(rate, 14000),
+	}
+
+	// Keep filter history so that adjusting rate on the fly won't click.
+	for i := range apu.filters {
+		filters[i].prevX = apu.filters[i].prevX
+		filters[i].prevY = apu.filters[i].prevY
+	}
+	apu.filters = filters
+}
+
+// SampleRate Return the rate of the mixed sample stream in Hz.
+func (apu *APU) SampleRate() float64 {
+	return apu.sampleRate
+}
+
+// ReadSamples Pull mixed samples out of APU, returns the number of samples copied.
+func (apu *APU) ReadSamples(buf []float32) int {
+	n := copy(buf, apu.samples)
+	apu.samples = apu.samples[:copy(apu.samples, apu.samples[n:])]
+	return n
+}
+
+// BufferedSamples Return the number of samples waiting to be pulled.
+func (apu *APU) BufferedSamples() int {
+	return len(apu.samples)
+}
+
+// CPU IO
+
+// CPURead CPU read from APU.
+func (apu *APU) CPURead(addr uint16, readOnly ...bool) uint8 {
+	bReadOnly := false
+	if len(readOnly) > 0 {
+		bReadOnly = readOnly[0]
+	}
+
+	var data uint8 = 0x00
+
+	if addr == 0x4015 {
+		if apu.pulse1.lengthValue > 0 {
+			data |= 0x01
+		}
+		if apu.pulse2.lengthValue > 0 {
+			data |= 0x02
+		}
+		if apu.triangle.lengthValue > 0 {
+			data |= 0x04
+		}
+		if apu.noise.lengthValue > 0 {
+			data |= 0x08
+		}
+		if apu.dmc.currentLength > 0 {
+			data |= 0x10
+		}
+		if apu.frameIRQ {
+			data |= 0x40
+		}
+		if apu.dmc.irqFlag {
+			data |= 0x80
+		}
+
+		// Reading status acknowledges the frame interrupt.
+		if !bReadOnly {
+			apu.frameIRQ = false
+			apu.updateIRQ()
+		}
+	}
+
+	return data
+}
+
+// CPUWrite CPU write to APU.
+func (apu *APU) CPUWrite(addr uint16, data uint8) {
+	switch addr {
+	case 0x4000:
+		apu.pulse1.writeControl(data)
+	case 0x4001:
+		apu.pulse1.writeSweep(data)
+	case 0x4002:
+		apu.pulse1.writeTimerLo(data)
+	case 0x4003:
+		apu.pulse1.writeTimerHi(data)
+	case 0x4004:
+		apu.pulse2.writeControl(data)
+	case 0x4005:
+		apu.pulse2.writeSweep(data)
+	case 0x4006:
+		apu.pulse2.writeTimerLo(data)
+	case 0x4007:
+		apu.pulse2.writeTimerHi(data)
+	case 0x4008:
+		apu.triangle.writeControl(data)
+	case 0x400A:
+		apu.triangle.writeTimerLo(data)
+	case 0x400B:
+		apu.triangle.writeTimerHi(data)
+	case 0x400C:
+		apu.noise.writeControl(data)
+	case 0x400E:
+		apu.noise.writePeriod(data)
+	case 0x400F:
+		apu.noise.writeLength(data)
+	case 0x4010:
+		apu.dmc.writeControl(data)
+	case 0x4011:
+		apu.dmc.value = data & 0x7F
+	case 0x4012:
+		apu.dmc.sampleAddress = 0xC000 | (uint16(data) << 6)
+	case 0x4013:
+		apu.dmc.sampleLength = (uint16(data) << 4) | 0x0001
+	case 0x4015:
+		apu.pulse1.setEnabled(data&0x01 != 0)
+		apu.pulse2.setEnabled(data&0x02 != 0)
+		apu.triangle.setEnabled(data&0x04 != 0)
+		apu.noise.setEnabled(data&0x08 != 0)
+		apu.dmc.setEnabled(data&0x10 != 0)
+		apu.dmc.irqFlag = false
+	case 0x4017:
+		apu.frameMode = data&0x80 != 0
+		apu.frameInhibit = data&0x40 != 0
+		if apu.frameInhibit {
+			apu.frameIRQ = false
+		}
+
+		// Writing $4017 restarts the sequence, 5-step mode also clocks
+		// all units immediately.
+		apu.frameCounter = 0
+		if apu.frameMode {
+			apu.clockQuarterFrame()
+			apu.clockHalfFrame()
+		}
+	}
+
+	apu.updateIRQ()
+}
+
+// Clock Clock APU once, it should be called once per CPU cycle.
+func (apu *APU) Clock() {
+	// Triangle and DMC timers run at CPU rate, the others at half of it.
+	apu.triangle.clockTimer()
+	apu.dmc.clockTimer(apu.bus)
+	if apu.clockCounter%2 == 0 {
+		apu.pulse1.clockTimer()
+		apu.pulse2.clockTimer()
+		apu.noise.clockTimer()
+	}
+
+	apu.clockFrameCounter()
+	apu.updateIRQ()
+
+	apu.sampleCounter++
+	if apu.sampleCounter >= apu.cyclesPerSample {
+		apu.sampleCounter -= apu.cyclesPerSample
+		apu.pushSample(apu.mix())
+	}
+
+	apu.clockCounter++
+}
+
+// Frame sequencer, see http://wiki.nesdev.com/w/index.php/APU_Frame_Counter
+func (apu *APU) clockFrameCounter() {
+	apu.frameCounter++
+
+	if !apu.frameMode {
+		// 4-step mode, raises IRQ at the end of the sequence.
+		switch apu.frameCounter {
+		case 7457, 22371:
+			apu.clockQuarterFrame()
+		case 14913:
+			apu.clockQuarterFrame()
+			apu.clockHalfFrame()
+		case 29828:
+			apu.setFrameIRQ()
+		case 29829:
+			apu.setFrameIRQ()
+			apu.clockQuarterFrame()
+			apu.clockHalfFrame()
+		case 29830:
+			apu.setFrameIRQ()
+			apu.frameCounter = 0
+		}
+	} else {
+		// 5-step mode, never raises IRQ.
+		switch apu.frameCounter {
+		case 7457, 22371:
+			apu.clockQuarterFrame()
+		case 14913, 37281:
+			apu.clockQuarterFrame()
+			apu.clockHalfFrame()
+		case 37282:
+			apu.frameCounter = 0
+		}
+	}
+}
+
+func (apu *APU) setFrameIRQ() {
+	if !apu.frameInhibit {
+		apu.frameIRQ = true
+	}
+}
+
+// Drive the shared IRQ line on bus with frame counter and DMC interrupts.
+func (apu *APU) updateIRQ() {
+	apu.bus.setIRQ(irqFrameCounter, apu.frameIRQ)
+	apu.bus.setIRQ(irqDMC, apu.dmc.irqFlag)
+}
+
+// Envelopes and triangle's linear counter.
+func (apu *APU) clockQuarterFrame() {
+	apu.pulse1.clockEnvelope()
+	apu.pulse2.clockEnvelope()
+	apu.triangle.clockLinearCounter()
+	apu.noise.clockEnvelope()
+}
+
+// Length counters and sweep units.
+func (apu *APU) clockHalfFrame() {
+	apu.pulse1.clockLength()
+	apu.pulse1.clockSweep()
+	apu.pulse2.clockLength()
+	apu.pulse2.clockSweep()
+	apu.triangle.clockLength()
+	apu.noise.clockLength()
+}
+
+func (apu *APU) mix() float32 {
+	p := pulseMixTable[apu.pulse1.output()+apu.pulse2.output()]
+	tnd := tndMixTable[3*uint16(apu.triangle.output())+2*uint16(apu.noise.output())+uint16(apu.dmc.value)]
+	return p + tnd
+}
+
+func (apu *APU) pushSample(sample float32) {
+	for i := range apu.filters {
+		sample = apu.filters[i].step(sample)
+	}
+
+	// Drop samples if nobody pulls them, one second of audio is plenty.
+	if len(apu.samples) < int(apu.sampleRate) {
+		apu.samples = append(apu.samples, sample)
+	}
+}
+
+// Pulse channel
+
+type apuPulse struct {
+	enabled bool
+	channel uint8
+
+	dutyMode  uint8
+	dutyValue uint8
+
+	lengthHalt  bool
+	lengthValue uint8
+
+	timerPeriod uint16
+	timerValue  uint16
+
+	constantVolume bool
+	envelopeStart  bool
+	envelopePeriod uint8
+	envelopeValue  uint8
+	envelopeVolume uint8
+
+	sweepEnabled bool
+	sweepReload  bool
+	sweepNegate  bool
+	sweepPeriod  uint8
+	sweepValue   uint8
+	sweepShift   uint8
+}
+
+func (pulse *apuPulse) setEnabled(v bool) {
+	pulse.enabled = v
+	if !v {
+		pulse.lengthValue = 0
+	}
+}
+
+// $4000/$4004 DDLC VVVV
+func (pulse *apuPulse) writeControl(data uint8) {
+	pulse.dutyMode = (data >> 6) & 0x03
+	pulse.lengthHalt = data&0x20 != 0
+	pulse.constantVolume = data&0x10 != 0
+	pulse.envelopePeriod = data & 0x0F
+	pulse.envelopeStart = true
+}
+
+// $4001/$4005 EPPP NSSS
+func (pulse *apuPulse) writeSweep(data uint8) {
+	pulse.sweepEnabled = data&0x80 != 0
+	pulse.sweepPeriod = (data >> 4) & 0x07
+	pulse.sweepNegate = data&0x08 != 0
+	pulse.sweepShift = data & 0x07
+	pulse.sweepReload = true
+}
+
+// $4002/$4006 TTTT TTTT
+func (pulse *apuPulse) writeTimerLo(data uint8) {
+	pulse.timerPeriod = (pulse.timerPeriod & 0xFF00) | uint16(data)
+}
+
+// $4003/$4007 LLLL LTTT
+func (pulse *apuPulse) writeTimerHi(data uint8) {
+	pulse.timerPeriod = (pulse.timerPeriod & 0x00FF) | (uint16(data&0x07) << 8)
+	if pulse.enabled {
+		pulse.lengthValue = lengthTable[data>>3]
+	}
+	pulse.envelopeStart = true
+	pulse.dutyValue = 0
+}
+
+func (pulse *apuPulse) clockTimer() {
+	if pulse.timerValue == 0 {
+		pulse.timerValue = pulse.timerPeriod
+		pulse.dutyValue = (pulse.dutyValue + 1) % 8
+	} else {
+		pulse.timerValue--
+	}
+}
+
+func (pulse *apuPulse) clockEnvelope() {
+	if pulse.envelopeStart {
+		pulse.envelopeVolume = 15
+		pulse.envelopeValue = pulse.envelopePeriod
+		pulse.envelopeStart = false
+	} else if pulse.envelopeValue > 0 {
+		pulse.envelopeValue--
+	} else {
+		pulse.envelopeValue = pulse.envelopePeriod
+		if pulse.envelopeVolume > 0 {
+			pulse.envelopeVolume--
+		} else if pulse.lengthHalt {
+			pulse.envelopeVolume = 15
+		}
+	}
+}
+
+func (pulse *apuPulse) clockLength() {
+	if !pulse.lengthHalt && pulse.lengthValue > 0 {
+		pulse.lengthValue--
+	}
+}
+
+// Target period of the sweep unit. Pulse 1 negates with one's complement,
+// pulse 2 with two's complement.
+func (pulse *apuPulse) sweepTarget() uint16 {
+	change := pulse.timerPeriod >> pulse.sweepShift
+	if pulse.sweepNegate {
+		if pulse.channel == 1 {
+			change++
+		}
+		if change > pulse.timerPeriod {
+			return 0
+		}
+		return pulse.timerPeriod - change
+	}
+	return pulse.timerPeriod + change
+}
+
+func (pulse *apuPulse) sweepMuted() bool {
+	return pulse.timerPeriod < 8 || pulse.sweepTarget() > 0x07FF
+}
+
+func (pulse *apuPulse) clockSweep() {
+	if pulse.sweepValue == 0 && pulse.sweepEnabled && pulse.sweepShift > 0 && !pulse.sweepMuted() {
+		pulse.timerPeriod = pulse.sweepTarget()
+	}
+
+	if pulse.sweepValue == 0 || pulse.sweepReload {
+		pulse.sweepValue = pulse.sweepPeriod
+		pulse.sweepReload = false
+	} else {
+		pulse.sweepValue--
+	}
+}
+
+func (pulse *apuPulse) output() uint8 {
+	if !pulse.enabled || pulse.lengthValue == 0 || pulse.sweepMuted() ||
+		dutyTable[pulse.dutyMode][pulse.dutyValue] == 0 {
+		return 0
+	}
+
+	if pulse.constantVolume {
+		return pulse.envelopePeriod
+	}
+	return pulse.envelopeVolume
+}
+
+// Triangle channel
+
+type apuTriangle struct {
+	enabled bool
+
+	dutyValue uint8
+
+	lengthHalt  bool // Also the linear counter control flag.
+	lengthValue uint8
+
+	timerPeriod uint16
+	timerValue  uint16
+
+	linearReload bool
+	linearPeriod uint8
+	linearValue  uint8
+}
+
+func (triangle *apuTriangle) setEnabled(v bool) {
+	triangle.enabled = v
+	if !v {
+		triangle.lengthValue = 0
+	}
+}
+
+// $4008 CRRR RRRR
+func (triangle *apuTriangle) writeControl(data uint8) {
+	triangle.lengthHalt = data&0x80 != 0
+	triangle.linearPeriod = data & 0x7F
+}
+
+// $400A TTTT TTTT
+func (triangle *apuTriangle) writeTimerLo(data uint8) {
+	triangle.timerPeriod = (triangle.timerPeriod & 0xFF00) | uint16(data)
+}
+
+// $400B LLLL LTTT
+func (triangle *apuTriangle) writeTimerHi(data uint8) {
+	triangle.timerPeriod = (triangle.timerPeriod & 0x00FF) | (uint16(data&0x07) << 8)
+	if triangle.enabled {
+		triangle.lengthValue = lengthTable[data>>3]
+	}
+	triangle.linearReload = true
+}
+
+func (triangle *apuTriangle) clockTimer() {
+	if triangle.timerValue == 0 {
+		triangle.timerValue = triangle.timerPeriod
+		if triangle.lengthValue > 0 && triangle.linearValue > 0 {
+			triangle.dutyValue = (triangle.dutyValue + 1) % 32
+		}
+	} else {
+		triangle.timerValue--
+	}
+}
+
+func (triangle *apuTriangle) clockLinearCounter() {
+	if triangle.linearReload {
+		triangle.linearValue = triangle.linearPeriod
+	} else if triangle.linearValue > 0 {
+		triangle.linearValue--
+	}
+
+	if !triangle.lengthHalt {
+		triangle.linearReload = false
+	}
+}
+
+func (triangle *apuTriangle) clockLength() {
+	if !triangle.lengthHalt && triangle.lengthValue > 0 {
+		triangle.lengthValue--
+	}
+}
+
+func (triangle *apuTriangle) output() uint8 {
+	if !triangle.enabled || triangle.lengthValue == 0 || triangle.linearValue == 0 {
+		return 0
+	}
+	return triangleTable[triangle.dutyValue]
+}
+
+// Noise channel
+
+type apuNoise struct {
+	enabled bool
+
+	mode          bool
+	shiftRegister uint16
+
+	lengthHalt  bool
+	lengthValue uint8
+
+	timerPeriod uint16
+	timerValue  uint16
+
+	constantVolume bool
+	envelopeStart  bool
+	envelopePeriod uint8
+	envelopeValue  uint8
+	envelopeVolume uint8
+}
+
+func (noise *apuNoise) setEnabled(v bool) {
+	noise.enabled = v
+	if !v {
+		noise.lengthValue = 0
+	}
+}
+
+// $400C --LC VVVV
+func (noise *apuNoise) writeControl(data uint8) {
+	noise.lengthHalt = data&0x20 != 0
+	noise.constantVolume = data&0x10 != 0
+	noise.envelopePeriod = data & 0x0F
+	noise.envelopeStart = true
+}
+
+// $400E M--- PPPP
+func (noise *apuNoise) writePeriod(data uint8) {
+	noise.mode = data&0x80 != 0
+	noise.timerPeriod = noiseTable[data&0x0F]
+}
+
+// $400F LLLL L---
+func (noise *apuNoise) writeLength(data uint8) {
+	if noise.enabled {
+		noise.lengthValue = lengthTable[data>>3]
+	}
+	noise.envelopeStart = true
+}
+
+func (noise *apuNoise) clockTimer() {
+	if noise.timerValue == 0 {
+		noise.timerValue = noise.timerPeriod - 1
+
+		// Mode flag selects between the 32767-step and the 93-step sequence.
+		var shift uint16 = 1
+		if noise.mode {
+			shift = 6
+		}
+		feedback := (noise.shiftRegister & 0x01) ^ ((noise.shiftRegister >> shift) & 0x01)
+		noise.shiftRegister >>= 1
+		noise.shiftRegister |= feedback << 14
+	} else {
+		noise.timerValue--
+	}
+}
+
+func (noise *apuNoise) clockEnvelope() {
+	if noise.envelopeStart {
+		noise.envelopeVolume = 15
+		noise.envelopeValue = noise.envelopePeriod
+		noise.envelopeStart = false
+	} else if noise.envelopeValue > 0 {
+		noise.envelopeValue--
+	} else {
+		noise.envelopeValue = noise.envelopePeriod
+		if noise.envelopeVolume > 0 {
+			noise.envelopeVolume--
+		} else if noise.lengthHalt {
+			noise.envelopeVolume = 15
+		}
+	}
+}
+
+func (noise *apuNoise) clockLength() {
+	if !noise.lengthHalt && noise.lengthValue > 0 {
+		noise.lengthValue--
+	}
+}
+
+func (noise *apuNoise) output() uint8 {
+	if !noise.enabled || noise.lengthValue == 0 || noise.shiftRegister&0x01 != 0 {
+		return 0
+	}
+
+	if noise.constantVolume {
+		return noise.envelopePeriod
+	}
+	return noise.envelopeVolume
+}
+
+// DMC channel
+
+type apuDMC struct {
+	enabled bool
+	value   uint8 // 7-bit output level
+
+	irqEnabled bool
+	irqFlag    bool
+	loop       bool
+
+	sampleAddress  uint16
+	sampleLength   uint16
+	currentAddress uint16
+	currentLength  uint16
+
+	shiftRegister uint8
+	bitCount      uint8
+
+	tickPeriod uint16
+	tickValue  uint16
+}
+
+func (dmc *apuDMC) setEnabled(v bool) {
+	dmc.enabled = v
+	if !v {
+		dmc.currentLength = 0
+	} else if dmc.currentLength == 0 {
+		dmc.restart()
+	}
+}
+
+// $4010 IL-- RRRR
+func (dmc *apuDMC) writeControl(data uint8) {
+	dmc.irqEnabled = data&0x80 != 0
+	if !dmc.irqEnabled {
+		dmc.irqFlag = false
+	}
+	dmc.loop = data&0x40 != 0
+	dmc.tickPeriod = dmcTable[data&0x0F]
+}
+
+func (dmc *apuDMC) restart() {
+	dmc.currentAddress = dmc.sampleAddress
+	dmc.currentLength = dmc.sampleLength
+}
+
+func (dmc *apuDMC) clockTimer(bus *Bus) {
+	if !dmc.enabled {
+		return
+	}
+
+	// Fetch the next sample byte once the output unit drained the last one.
+	// The real hardware stalls the CPU for a few cycles here, which we ignore.
+	if dmc.currentLength > 0 && dmc.bitCount == 0 {
+		dmc.shiftRegister = bus.CPURead(dmc.currentAddress)
+		dmc.bitCount = 8
+		dmc.currentAddress++
+		if dmc.currentAddress == 0x0000 {
+			dmc.currentAddress = 0x8000
+		}
+		dmc.currentLength--
+		if dmc.currentLength == 0 {
+			if dmc.loop {
+				dmc.restart()
+			} else if dmc.irqEnabled {
+				dmc.irqFlag = true
+			}
+		}
+	}
+
+	if dmc.tickValue == 0 {
+		dmc.tickValue = dmc.tickPeriod - 1
+		dmc.clockShifter()
+	} else {
+		dmc.tickValue--
+	}
+}
+
+func (dmc *apuDMC) clockShifter() {
+	if dmc.bitCount == 0 {
+		return
+	}
+
+	if dmc.shiftRegister&0x01 != 0 {
+		if dmc.value <= 125 {
+			dmc.value += 2
+		}
+	} else {
+		if dmc.value >= 2 {
+			dmc.value -= 2
+		}
+	}
+	dmc.shiftRegister >>= 1
+	dmc.bitCount--
+}
+
+// Output filters
+
+// First order IIR filter, y[n] = b0*x[n] + b1*x[n-1] - a1*y[n-1]
+type apuFilter struct {
+	b0, b1, a1 float32
+	prevX      float32
+	prevY      float32
+}
+
+func newLowPassFilter(sampleRate float64, cutoff float64) apuFilter {
+	c := sampleRate / (math.Pi * cutoff)
+	a0 := 1 / (1 + c)
+	return apuFilter{
+		b0: float32(a0),
+		b1: float32(a0),
+		a1: float32((1 - c) * a0),
+	}
+}
+
+func newHighPassFilter(sampleRate float64, cutoff float64) apuFilter {
+	c := sampleRate / (math.Pi * cutoff)
+	a0 := 1 / (1 + c)
+	return apuFilter{
+		b0: float32(c * a0),
+		b1: float32(-c * a0),
+		a1: float32((1 - c) * a0),
+	}
+}
+
+func (filter *apuFilter) step(x float32) float32 {
+	y := filter.b0*x + filter.b1*filter.prevX - filter.a1*filter.prevY
+	filter.prevX = x
+	filter.prevY = y
+	return y
+}
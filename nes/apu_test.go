@@ -0,0 +1,60 @@
+package nes
+
+import "testing"
+
+// Clock APU until tick reports the timer expired count+1 times and return
+// CPU cycles between the last two.
+func apuPeriod(apu *APU, tick func() bool) int {
+	last, count := -1, 0
+	for cycle := 0; cycle < 100000; cycle++ {
+		if !tick() {
+			continue
+		}
+		if count++; count > 3 {
+			return cycle - last
+		}
+		last = cycle
+	}
+	return -1
+}
+
+// TestAPUTimerPeriods Noise and DMC timers expire once every period of the
+// NTSC tables, in CPU cycles.
+func TestAPUTimerPeriods(t *testing.T) {
+	noisePeriods := []int{4, 8, 16, 32, 64, 96, 128, 160, 202, 254, 380, 508, 762, 1016, 2034, 4068}
+	for rate, expected := range noisePeriods {
+		bus, err := newTestBus(makeROM(0, 1, 1, 0, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		apu := bus.APU
+		apu.CPUWrite(0x4015, 0x08)
+		apu.CPUWrite(0x400E, uint8(rate))
+		period := apuPeriod(apu, func() bool {
+			shiftRegister := apu.noise.shiftRegister
+			apu.Clock()
+			return apu.noise.shiftRegister != shiftRegister
+		})
+		if period != expected {
+			t.Errorf("noise rate %d: period of %d cycles, expected %d", rate, period, expected)
+		}
+	}
+
+	for rate, expected := range dmcTable {
+		bus, err := newTestBus(makeROM(0, 1, 1, 0, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		apu := bus.APU
+		apu.CPUWrite(0x4010, uint8(rate))
+		apu.CPUWrite(0x4015, 0x10)
+		period := apuPeriod(apu, func() bool {
+			tickValue := apu.dmc.tickValue
+			apu.Clock()
+			return apu.dmc.tickValue > tickValue
+		})
+		if period != int(expected) {
+			t.Errorf("DMC rate %d: period of %d cycles, expected %d", rate, period, expected)
+		}
+	}
+}
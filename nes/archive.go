@@ -0,0 +1,74 @@
+package nes
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Open a ROM file, transparently unpacking .gz files and the first .nes entry
+// of .zip files.
+func openROM(filePath string) (io.ReadCloser, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".zip":
+		return openZip(filePath)
+	case ".gz":
+		return openGzip(filePath)
+	default:
+		return os.Open(filePath)
+	}
+}
+
+func openZip(filePath string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range archive.File {
+		if strings.ToLower(filepath.Ext(entry.Name)) != ".nes" {
+			continue
+		}
+
+		file, err := entry.Open()
+		if err != nil {
+			archive.Close()
+			return nil, err
+		}
+		return &archiveReader{file, archive}, nil
+	}
+
+	archive.Close()
+	return nil, ErrNoROMInArchive
+}
+
+func openGzip(filePath string) (io.ReadCloser, error) {
+	raw, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := gzip.NewReader(raw)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return &archiveReader{file, raw}, nil
+}
+
+// Reader of an archive entry, closing it also closes the archive.
+type archiveReader struct {
+	io.ReadCloser
+	archive io.Closer
+}
+
+func (reader *archiveReader) Close() error {
+	err := reader.ReadCloser.Close()
+	if archiveErr := reader.archive.Close(); err == nil {
+		err = archiveErr
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package nes
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+type archiveEntry struct {
+	name string
+	data []byte
+}
+
+func makeZip(t *testing.T, entries ...archiveEntry) []byte {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for _, entry := range entries {
+		w, err := writer.Create(entry.name)
+		if err == nil {
+			_, err = w.Write(entry.data)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+func makeGzip(t *testing.T, data []byte) []byte {
+	var archive bytes.Buffer
+	writer := gzip.NewWriter(&archive)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archive.Bytes()
+}
+
+// TestArchive Zipped and gzipped ROMs load like plain ones, from the first
+// .nes entry of a zip.
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rom := makeROM(0, 1, 1, 0, []byte{0x4C, 0x00, 0x80})
+	other := makeROM(2, 2, 0, 0, nil)
+	expected, err := NewCartridgeFromBytes(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"game.nes", rom, nil},
+		{"game.NES.gz", makeGzip(t, rom), nil},
+		{"game.zip", makeZip(t, archiveEntry{"game.nes", rom}), nil},
+		{"first.zip", makeZip(t,
+			archiveEntry{"readme.txt", []byte("not a ROM")},
+			archiveEntry{"roms/Game.NES", rom},
+			archiveEntry{"other.nes", other},
+		), nil},
+		{"none.zip", makeZip(t, archiveEntry{"readme.txt", []byte("not a ROM")}, archiveEntry{"game.nes.bak", rom}), ErrNoROMInArchive},
+		{"empty.zip", makeZip(t), ErrNoROMInArchive},
+		{"bad.gz", rom, gzip.ErrHeader},
+		{"bad.zip", rom, zip.ErrFormat},
+		{"truncated.gz", makeGzip(t, rom)[:100], io.ErrUnexpectedEOF},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := ioutil.WriteFile(path, test.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		cart, err := NewCartridge(path)
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: got %v, expected %v", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if cart.Hash() != expected.Hash() {
+			t.Errorf("%s: got %+v, expected %+v", test.name, cart.Info(), expected.Info())
+		}
+	}
+}
+
+// TestNewCartridgeFromReader Readers returning a few bytes at a time are read
+// in full, the same as a byte slice.
+func TestNewCartridgeFromReader(t *testing.T) {
+	rom := makeROM(1, 2, 1, 0x02, nil)
+	expected, err := NewCartridgeFromBytes(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gzipped, err := gzip.NewReader(bytes.NewReader(makeGzip(t, rom)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, reader := range map[string]io.Reader{
+		"one byte": iotest.OneByteReader(bytes.NewReader(rom)),
+		"half":     iotest.HalfReader(bytes.NewReader(rom)),
+		"gzip":     gzipped,
+	} {
+		cart, err := NewCartridgeFromReader(reader)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if cart.Hash() != expected.Hash() || cart.Info() != expected.Info() {
+			t.Errorf("%s: got %+v, expected %+v", name, cart.Info(), expected.Info())
+		}
+	}
+
+	if _, err := NewCartridgeFromReader(iotest.DataErrReader(bytes.NewReader(rom[:20]))); !errors.Is(err, ErrTruncated) {
+		t.Errorf("got %v, expected ErrTruncated", err)
+	}
+}
@@ -1,10 +1,23 @@
 package nes
 
+import "bytes"
+
+// Devices that may pull the shared IRQ line low.
+const (
+	irqFrameCounter = (1 << 0)
+	irqDMC          = (1 << 1)
+	irqMapper       = (1 << 2)
+)
+
+// Internal RAM size, CPU RAM above it is only mirrors.
+const cpuRAMSize = 0x0800
+
 // Bus The main bus of a NES.
 type Bus struct {
 	CPU        *CPU
 	CPURAM     []uint8
 	PPU        *PPU
+	APU        *APU
 	cartridge  *Cartridge
 	Controller []uint8
 
@@ -17,6 +30,8 @@ type Bus struct {
 	dmaTransfer bool
 	dmaDummy    bool
 
+	irqLine uint8 // Each bit represents an asserted IRQ source.
+
 	systemClockCounter uint32
 }
 
@@ -27,6 +42,7 @@ func NewBus() *Bus {
 	bus := Bus{CPU: nil,
 		CPURAM:          ram,
 		PPU:             nil,
+		APU:             nil,
 		cartridge:       nil,
 		Controller:      make([]uint8, 2),
 		controllerState: make([]uint8, 2)}
@@ -34,10 +50,16 @@ func NewBus() *Bus {
 	// Connect CPU to bus
 	bus.CPU = ConnectCPU(&bus)
 	bus.PPU = ConnectPPU(&bus)
+	bus.APU = ConnectAPU(&bus)
 	bus.dmaDummy = false
 	return &bus
 }
 
+// RAM The 2KB of internal RAM, without its mirrors.
+func (bus *Bus) RAM() []uint8 {
+	return bus.CPURAM[:cpuRAMSize]
+}
+
 // CPU IO
 
 // CPURead Allow CPU read from bus.
@@ -54,6 +76,8 @@ func (bus *Bus) CPURead(addr uint16, readOnly ...bool) uint8 {
 		data = bus.CPURAM[addr&0x07FF] // addr&0x07FF yields back the geniune value after mirroring
 	} else if addr >= 0x2000 && addr <= 0x3FFF {
 		data = bus.PPU.CPURead(addr&0x0007, bReadOnly)
+	} else if addr == 0x4015 {
+		data = bus.APU.CPURead(addr, bReadOnly)
 	} else if addr >= 0x4016 && addr <= 0x4017 {
 		data = 0
 		if (bus.controllerState[addr&0x0001] & 0x80) > 0 {
@@ -73,12 +97,16 @@ func (bus *Bus) CPUWrite(addr uint16, data uint8) {
 		bus.CPURAM[addr&0x07FF] = data // addr&0x07FF yields back the geniune value after mirroring
 	} else if addr >= 0x2000 && addr < 0x3FFF {
 		bus.PPU.CPUWrite(addr&0x0007, data)
+	} else if (addr >= 0x4000 && addr <= 0x4013) || addr == 0x4015 || addr == 0x4017 {
+		bus.APU.CPUWrite(addr, data)
 	} else if addr == 0x4014 {
 		bus.dmaPage = data
 		bus.dmaAddr = 0x00
 		bus.dmaTransfer = true
-	} else if addr >= 0x4016 && addr <= 0x4017 {
-		bus.controllerState[addr&0x0001] = bus.Controller[addr&0x0001]
+	} else if addr == 0x4016 {
+		// Strobing $4016 latches both controllers, $4017 belongs to APU.
+		bus.controllerState[0] = bus.Controller[0]
+		bus.controllerState[1] = bus.Controller[1]
 	}
 }
 
@@ -92,15 +120,39 @@ func (bus *Bus) InsertCartridge(cart *Cartridge) {
 
 // Reset Reset whole bus and the devices attached to it.
 func (bus *Bus) Reset() {
+	bus.cartridge.Reset()
 	bus.CPU.Reset()
+	bus.APU.Reset()
+	bus.irqLine = 0
 	bus.systemClockCounter = 0
 }
 
+// PowerCycle Turn the console off and on. Unlike Reset, every device and all
+// volatile memory come back exactly as on a freshly built bus, so emulation
+// from here is reproducible. Battery-backed RAM is kept.
+func (bus *Bus) PowerCycle() error {
+	bus.cartridge.powerOn()
+
+	fresh := NewBus()
+	fresh.InsertCartridge(bus.cartridge)
+	fresh.Reset()
+
+	var state bytes.Buffer
+	if err := fresh.SaveState(&state); err != nil {
+		return err
+	}
+	return bus.LoadState(&state)
+}
+
 // Clock Clock bus once.
 func (bus *Bus) Clock() {
 	bus.PPU.Clock()
 
 	if bus.systemClockCounter%3 == 0 {
+		// APU and M2 keep running during DMA.
+		bus.APU.Clock()
+		bus.cartridge.CPUCycle()
+
 		if bus.dmaTransfer {
 			if bus.dmaDummy {
 				if bus.systemClockCounter%2 == 1 {
@@ -110,7 +162,8 @@ func (bus *Bus) Clock() {
 				if bus.systemClockCounter%2 == 0 {
 					bus.dmaData = bus.CPURead(uint16(bus.dmaPage)<<8 | uint16(bus.dmaAddr))
 				} else {
-					bus.PPU.OAM[bus.dmaAddr] = bus.dmaData
+					// DMA writes through $2004, starting from current OAM address.
+					bus.PPU.writeOAM(bus.dmaData)
 					bus.dmaAddr++
 					if bus.dmaAddr == 0x00 {
 						bus.dmaTransfer = false
@@ -119,6 +172,11 @@ func (bus *Bus) Clock() {
 				}
 			}
 		} else {
+			// IRQ is level triggered, service it between instructions.
+			bus.setIRQ(irqMapper, bus.cartridge.IRQState())
+			if bus.irqLine != 0 && bus.CPU.Complete() {
+				bus.CPU.IRQ()
+			}
 			bus.CPU.Clock()
 		}
 	}
@@ -129,3 +187,23 @@ func (bus *Bus) Clock() {
 	}
 	bus.systemClockCounter++
 }
+
+// RunFrame Clock the bus until PPU completes a frame.
+func (bus *Bus) RunFrame() {
+	// Golang's do while.
+	for done := true; done; done = bus.PPU.FrameComplete != true {
+		bus.Clock()
+	}
+	bus.PPU.FrameComplete = false
+}
+
+// Interrupts
+
+// Assert or release an IRQ source on the shared IRQ line.
+func (bus *Bus) setIRQ(source uint8, v bool) {
+	if v {
+		bus.irqLine |= source
+	} else {
+		bus.irqLine &= ^source
+	}
+}
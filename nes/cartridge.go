@@ -1,10 +1,17 @@
 package nes
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 const (
@@ -12,20 +19,106 @@ const (
 	mirrorVertical
 	oneScreenLo
 	oneScrennHi
+	mirrorFourScreen // Cartridge supplies VRAM for the other two name tables.
+	mirrorHardware   // Mirroring is decided by the board, see Mapper.Mirror().
 )
 
+// Header formats
+const (
+	FormatArchaicINES = iota // iNES with garbage in bytes 7-15, such as "DiskDude!".
+	FormatINES
+	FormatNES20
+)
+
+// CPU/PPU timings
+const (
+	TimingNTSC = iota
+	TimingPAL
+	TimingMulti // Runs on both NTSC and PAL consoles.
+	TimingDendy
+)
+
+// Console types
+const (
+	ConsoleNES = iota
+	ConsoleVsSystem
+	ConsolePlayChoice
+	ConsoleExtended
+)
+
+// CartridgeInfo Board description read from the file header. Sizes are in
+// bytes, fields only NES 2.0 can express are zero for iNES files.
+type CartridgeInfo struct {
+	Format int
+
+	Mapper    uint16
+	Submapper uint8
+
+	PRGROMSize   int
+	CHRROMSize   int
+	PRGRAMSize   int
+	PRGNVRAMSize int
+	CHRRAMSize   int
+	CHRNVRAMSize int
+
+	Battery    bool
+	Trainer    bool
+	FourScreen bool
+	Mirror     int // MirrorHorizontal or MirrorVertical as soldered on board.
+
+	Timing          int
+	ConsoleType     int
+	VsPPUType       uint8
+	VsHardwareType  uint8
+	ExtendedConsole uint8
+	MiscROMs        uint8
+	ExpansionDevice uint8
+
+	// Mapper, mirroring, battery and timing were corrected from game database.
+	Corrected bool
+}
+
+// ROMHash Hashes of PRG ROM followed by CHR ROM, header and trainer excluded.
+type ROMHash struct {
+	CRC32 uint32
+	SHA1  [20]byte
+}
+
+// Largest PRG or CHR ROM accepted, NES 2.0 can describe absurd sizes.
+const maxROMSize = 64 * 1024 * 1024
+
 // Cartridge NES game cartridge.
 type Cartridge struct {
 	mirror int
+	info   CartridgeInfo
+	hash   ROMHash
+	title  string
+
+	patches []AppliedPatch
 
 	prgMemory []uint8
 	chrMemory []uint8
+	prgRAM    []uint8 // $6000-$7FFF work RAM
+	trainer   []uint8 // Copied to $7000-$71FF at power-up
+	vram      []uint8 // Name tables 2 and 3 of four-screen boards
 
-	mapperID uint8
-	prgBanks uint8
-	chrBanks uint8
+	// Battery-backed PRG RAM is kept in a .sav file next to the ROM.
+	battery     bool
+	savePath    string
+	prgRAMDirty bool
 
-	mapper Mapper
+	mapperID  uint16
+	submapper uint8
+	prgBanks  uint8
+	chrBanks  uint8
+
+	mapper       Mapper
+	irqSource    IRQSource
+	ppuWatcher   PPUAddressWatcher
+	cycleWatcher CPUCycleWatcher
+	busConflicts bool
+
+	nameTableMapper NameTableMapper
 }
 
 type cartridgeHeader struct {
@@ -34,80 +127,594 @@ type cartridgeHeader struct {
 	CHRROMChunks byte
 	Mapper1      byte
 	Mapper2      byte
-	PRGRAMSize   byte
 
-	// Unused
-	TVSystem1 byte
-	TVSystem2 byte
-	Unused    [5]byte
+	// NES 2.0 layout, iNES only uses PRG RAM size in byte 8 and TV system in
+	// byte 9.
+	Mapper3         byte // Submapper and mapper MSB
+	ROMSizeMSB      byte // CHR and PRG ROM size MSB
+	PRGRAMShift     byte // PRG NVRAM and PRG RAM shift count
+	CHRRAMShift     byte // CHR NVRAM and CHR RAM shift count
+	Timing          byte
+	VsSystem        byte // Vs. hardware and PPU type, or extended console type
+	MiscROMs        byte
+	ExpansionDevice byte
 }
 
 // NewCartridge Load a .nes file and return a Cartridge struct.
-func NewCartridge(filePath string) (*Cartridge, error) {
-	cart := Cartridge{}
-	file, err := os.Open(filePath)
+// Files ending with .zip or .gz are unpacked first, see openROM. IPS, UPS and
+// BPS patches are applied in given order before anything else, see Patches.
+func NewCartridge(filePath string, patchPaths ...string) (*Cartridge, error) {
+	file, err := openROM(filePath)
+	if err != nil {
+		return nil, &ROMError{"file", err}
+	}
+	rom, err := ioutil.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, &ROMError{"file", err}
+	}
+
+	var patches []AppliedPatch
+	for _, patchPath := range patchPaths {
+		patch, err := ioutil.ReadFile(patchPath)
+		if err != nil {
+			return nil, &ROMError{"patch", err}
+		}
+
+		var format string
+		rom, format, err = ApplyPatch(rom, patch)
+		if err != nil {
+			return nil, &ROMError{"patch", fmt.Errorf("%s: %w", patchPath, err)}
+		}
+		patches = append(patches, AppliedPatch{filepath.Base(patchPath), format})
+	}
+
+	cart, err := NewCartridgeFromBytes(rom)
 	if err != nil {
-		fmt.Printf("Failed to load cartridge: %s\n", err)
 		return nil, err
 	}
-	defer file.Close()
+	cart.patches = patches
+
+	// game.nes, game.zip and game.nes.gz all save to game.sav.
+	savePath := filePath
+	if strings.ToLower(filepath.Ext(savePath)) == ".gz" {
+		savePath = strings.TrimSuffix(savePath, filepath.Ext(savePath))
+	}
+	cart.savePath = strings.TrimSuffix(savePath, filepath.Ext(savePath)) + ".sav"
+	if cart.title == "" {
+		cart.title = filepath.Base(strings.TrimSuffix(savePath, filepath.Ext(savePath)))
+	}
+	if cart.battery {
+		if data, err := ioutil.ReadFile(cart.savePath); err == nil {
+			if err := cart.LoadSaveData(data); err != nil {
+				return nil, &ROMError{"save", err}
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, &ROMError{"save", err}
+		}
+		// Trainer sits over what the save restored, as after power-up.
+		copy(cart.prgRAM[0x1000:], cart.trainer)
+	}
+
+	return cart, nil
+}
+
+// NewCartridgeFromBytes Load a cartridge from .nes file contents.
+func NewCartridgeFromBytes(data []byte) (*Cartridge, error) {
+	return NewCartridgeFromReader(bytes.NewReader(data))
+}
+
+// NewCartridgeFromReader Load a cartridge from a reader of .nes file contents.
+// The cartridge has no save file, see SetSavePath and LoadSaveData.
+func NewCartridgeFromReader(file io.Reader) (*Cartridge, error) {
+	cart := Cartridge{}
+	var err error
+
+	raw := make([]uint8, binary.Size(cartridgeHeader{}))
+	if err := readFull(file, raw); err != nil {
+		return nil, &ROMError{"header", err}
+	}
 
 	header := cartridgeHeader{}
-	if err := binary.Read(file, binary.LittleEndian, &header); err != nil {
-		fmt.Printf("Failed to to read file header: %s\n", err)
+	binary.Read(bytes.NewReader(raw), binary.LittleEndian, &header)
+	if header.Name != [4]byte{0x4E, 0x45, 0x53, 0x1A} {
+		return nil, &ROMError{"header", ErrBadMagic}
+	}
+
+	cart.info = parseHeader(&header)
+	if cart.info.PRGROMSize <= 0 || cart.info.PRGROMSize > maxROMSize {
+		return nil, &ROMError{"PRG ROM", fmt.Errorf("%w: %d bytes", ErrBadSize, cart.info.PRGROMSize)}
+	}
+	if cart.info.CHRROMSize < 0 || cart.info.CHRROMSize > maxROMSize {
+		return nil, &ROMError{"CHR ROM", fmt.Errorf("%w: %d bytes", ErrBadSize, cart.info.CHRROMSize)}
+	}
+
+	// Trainer was loaded by copier hardware before the game itself.
+	if cart.info.Trainer {
+		cart.trainer = make([]uint8, 512)
+		if err := readFull(file, cart.trainer); err != nil {
+			return nil, &ROMError{"trainer", err}
+		}
+	}
+
+	cart.prgMemory, cart.prgBanks, err = readROM(file, cart.info.PRGROMSize, 16384)
+	if err != nil {
+		return nil, &ROMError{"PRG ROM", err}
+	}
+
+	if cart.info.CHRROMSize == 0 {
+		// No CHR ROM means the board has CHR RAM instead, at least 8KB.
+		chrRAMSize := cart.info.CHRRAMSize + cart.info.CHRNVRAMSize
+		if chrRAMSize < 8192 {
+			chrRAMSize = 8192
+		}
+		cart.chrMemory = make([]uint8, chrRAMSize)
+	} else {
+		cart.chrMemory, cart.chrBanks, err = readROM(file, cart.info.CHRROMSize, 8192)
+		if err != nil {
+			return nil, &ROMError{"CHR ROM", err}
+		}
+	}
+
+	// Identify the dump, headers in the wild are often wrong.
+	cart.hash = hashROM(cart.prgMemory, cart.info.PRGROMSize, cart.chrMemory, cart.info.CHRROMSize)
+	if game, ok := LookupGame(cart.hash.CRC32, cart.hash.SHA1); ok {
+		cart.title = game.Title
+		cart.info.correct(&game)
+	}
+
+	cart.mapperID = cart.info.Mapper
+	cart.submapper = cart.info.Submapper
+	cart.mirror = cart.info.Mirror
+	if cart.info.FourScreen {
+		cart.mirror = mirrorFourScreen
+		cart.vram = make([]uint8, 2048)
+	}
+
+	// Mappers map 8KB at $6000-$7FFF, so never go below that.
+	prgRAMSize := cart.info.PRGRAMSize + cart.info.PRGNVRAMSize
+	if prgRAMSize < 8192 {
+		prgRAMSize = 8192
+	}
+	cart.prgRAM = make([]uint8, prgRAMSize)
+	copy(cart.prgRAM[0x1000:], cart.trainer)
+
+	cart.battery = cart.info.Battery || cart.info.PRGNVRAMSize > 0
+
+	if err := cart.attachMapper(); err != nil {
 		return nil, err
 	}
+	return &cart, nil
+}
 
-	if header.Name != [4]byte{0x4E, 0x45, 0x53, 0x1A} {
-		fmt.Printf("Failed to load cartridge: This is not a valid file.")
+// Create mapper of the board and find what optional features it has.
+func (cart *Cartridge) attachMapper() error {
+	cart.mapper = nil
+	factory, ok := lookupMapper(cart.mapperID, cart.submapper)
+	if ok {
+		cart.mapper = factory(cart.info, cart.prgBanks, cart.chrBanks)
+	}
+	if cart.mapper == nil {
+		return &ROMError{"mapper", &MapperError{cart.mapperID, cart.submapper}}
+	}
+
+	// Optional mapper features.
+	cart.irqSource, _ = cart.mapper.(IRQSource)
+	cart.ppuWatcher, _ = cart.mapper.(PPUAddressWatcher)
+	cart.cycleWatcher, _ = cart.mapper.(CPUCycleWatcher)
+	cart.nameTableMapper, _ = cart.mapper.(NameTableMapper)
+	if cart.nameTableMapper != nil && cart.vram == nil {
+		// Boards mapping name tables themselves may use extra VRAM of their own.
+		cart.vram = make([]uint8, 2048)
+	}
+	if conflicter, ok := cart.mapper.(BusConflicter); ok {
+		cart.busConflicts = conflicter.BusConflicts()
+	}
+	return nil
+}
+
+// Clone Return a copy of the cartridge in its current state, sharing no
+// memory or mapper with it, so two machines can run the same game side by
+// side. The copy has no .sav file.
+func (cart *Cartridge) Clone() (*Cartridge, error) {
+	clone := *cart
+	clone.prgMemory = append([]uint8(nil), cart.prgMemory...)
+	clone.chrMemory = append([]uint8(nil), cart.chrMemory...)
+	clone.prgRAM = append([]uint8(nil), cart.prgRAM...)
+	clone.vram = append([]uint8(nil), cart.vram...)
+	clone.savePath = ""
+
+	if err := clone.attachMapper(); err != nil {
+		return nil, err
+	}
+	var registers bytes.Buffer
+	if err := WriteState(&registers, cart.mapper.StateFields()...); err != nil {
 		return nil, err
 	}
+	if err := ReadState(&registers, clone.mapper.StateFields()...); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
 
-	// Unused.
-	if header.Mapper1&0x04 != 0 {
-		trainer := make([]byte, 512)
-		if _, err := io.ReadFull(file, trainer); err != nil {
-			fmt.Printf("Failed to read cartridge: %s\n", err)
-			return nil, err
-		}
+// Decode iNES or NES 2.0 file header.
+// See http://wiki.nesdev.com/w/index.php/NES_2.0
+func parseHeader(header *cartridgeHeader) CartridgeInfo {
+	info := CartridgeInfo{}
+
+	if header.Mapper2&0x0C == 0x08 {
+		info.Format = FormatNES20
+	} else if header.Mapper2&0x0C == 0x00 && header.MiscROMs == 0 && header.ExpansionDevice == 0 &&
+		header.Timing == 0 && header.VsSystem == 0 {
+		info.Format = FormatINES
+	} else {
+		info.Format = FormatArchaicINES
 	}
 
-	// Determine mapper ID
-	cart.mapperID = ((header.Mapper2 >> 4) << 4) | (header.Mapper1 >> 4)
-	if (header.Mapper1 & 0x01) != 0 {
-		cart.mirror = mirrorVertical
+	info.Battery = header.Mapper1&0x02 != 0
+	info.Trainer = header.Mapper1&0x04 != 0
+	info.FourScreen = header.Mapper1&0x08 != 0
+	if header.Mapper1&0x01 != 0 {
+		info.Mirror = mirrorVertical
 	} else {
-		cart.mirror = mirrorHorizontal
+		info.Mirror = mirrorHorizontal
 	}
 
-	var fileType uint8 = 1
-	switch fileType {
-	case 0:
-	case 1:
-		cart.prgBanks = header.PRGROMChunks
-		cart.prgMemory = make([]uint8, int(cart.prgBanks)*16384)
-		if _, err := io.ReadFull(file, cart.prgMemory); err != nil {
-			fmt.Printf("Failed to read PRG banks: %s\n", err)
-			return nil, err
+	switch info.Format {
+	case FormatArchaicINES:
+		// Bytes 7-15 can't be trusted, only the lower mapper nibble is usable.
+		info.Mapper = uint16(header.Mapper1 >> 4)
+		info.PRGROMSize = int(header.PRGROMChunks) * 16384
+		info.CHRROMSize = int(header.CHRROMChunks) * 8192
+		info.PRGRAMSize = 8192
+	case FormatINES:
+		info.Mapper = uint16(header.Mapper2&0xF0) | uint16(header.Mapper1>>4)
+		info.PRGROMSize = int(header.PRGROMChunks) * 16384
+		info.CHRROMSize = int(header.CHRROMChunks) * 8192
+		info.ConsoleType = int(header.Mapper2 & 0x03)
+
+		// Zero PRG RAM size means 8KB for compatibility.
+		prgRAMSize := int(header.Mapper3) * 8192
+		if prgRAMSize == 0 {
+			prgRAMSize = 8192
+		}
+		if info.Battery {
+			info.PRGNVRAMSize = prgRAMSize
+		} else {
+			info.PRGRAMSize = prgRAMSize
+		}
+		if info.CHRROMSize == 0 {
+			info.CHRRAMSize = 8192
 		}
 
-		cart.chrBanks = header.CHRROMChunks
-		cart.chrMemory = make([]uint8, int(cart.chrBanks)*8192)
-		if _, err := io.ReadFull(file, cart.chrMemory); err != nil {
-			fmt.Printf("Failed to read CHR banks: %s\n", err)
-			return nil, err
+		if header.ROMSizeMSB&0x01 != 0 {
+			info.Timing = TimingPAL
 		}
-	case 2:
+	case FormatNES20:
+		info.Mapper = uint16(header.Mapper3&0x0F)<<8 | uint16(header.Mapper2&0xF0) | uint16(header.Mapper1>>4)
+		info.Submapper = header.Mapper3 >> 4
+		info.PRGROMSize = romSize(header.PRGROMChunks, header.ROMSizeMSB&0x0F, 16384)
+		info.CHRROMSize = romSize(header.CHRROMChunks, header.ROMSizeMSB>>4, 8192)
+		info.PRGRAMSize = ramSize(header.PRGRAMShift & 0x0F)
+		info.PRGNVRAMSize = ramSize(header.PRGRAMShift >> 4)
+		info.CHRRAMSize = ramSize(header.CHRRAMShift & 0x0F)
+		info.CHRNVRAMSize = ramSize(header.CHRRAMShift >> 4)
+		info.Timing = int(header.Timing & 0x03)
+
+		info.ConsoleType = int(header.Mapper2 & 0x03)
+		switch info.ConsoleType {
+		case ConsoleVsSystem:
+			info.VsPPUType = header.VsSystem & 0x0F
+			info.VsHardwareType = header.VsSystem >> 4
+		case ConsoleExtended:
+			info.ExtendedConsole = header.VsSystem & 0x0F
+		}
+
+		info.MiscROMs = header.MiscROMs & 0x03
+		info.ExpansionDevice = header.ExpansionDevice & 0x3F
 	}
-	// Close file once done.
-	file.Close()
 
-	switch cart.mapperID {
-	case 0:
-		cart.mapper = NewMapper0(cart.prgBanks, cart.chrBanks)
+	return info
+}
+
+// Get NES 2.0 ROM size in bytes. MSB nibble 0xF switches LSB to exponent-multiplier
+// notation, EEEEEEMM giving 2^E * (MM*2+1) bytes.
+func romSize(lsb uint8, msb uint8, unit int) int {
+	if msb == 0x0F {
+		if lsb>>2 > 30 {
+			// Way beyond maxROMSize, don't let it overflow.
+			return -1
+		}
+		return (1 << (lsb >> 2)) * (int(lsb&0x03)*2 + 1)
 	}
+	return (int(msb)<<8 | int(lsb)) * unit
+}
 
-	return &cart, nil
+// Get NES 2.0 RAM size in bytes from its shift count.
+func ramSize(shift uint8) int {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << shift
+}
+
+// Read ROM of given size, padded with mirrors to a whole number of banks.
+func readROM(r io.Reader, size int, bankSize int) ([]uint8, uint8, error) {
+	data := make([]uint8, size)
+	if err := readFull(r, data); err != nil {
+		return nil, 0, err
+	}
+
+	banks := (size + bankSize - 1) / bankSize
+	if banks > 0xFF {
+		// Mappers address at most 255 banks.
+		banks = 0xFF
+	}
+
+	rom := make([]uint8, banks*bankSize)
+	for i := range rom {
+		rom[i] = data[i%size]
+	}
+
+	return rom, uint8(banks), nil
+}
+
+// Read exactly len(buf) bytes, reporting short files as TruncatedError.
+func readFull(r io.Reader, buf []uint8) error {
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &TruncatedError{len(buf), n}
+	}
+	return err
+}
+
+// Overwrite header fields with what game database knows.
+func (info *CartridgeInfo) correct(game *GameInfo) {
+	info.Mapper = game.Mapper
+	info.Submapper = game.Submapper
+	if game.Mirror >= 0 || game.FourScreen {
+		// Only when the entry states mirroring, "-" keeps the header's.
+		if game.Mirror >= 0 {
+			info.Mirror = game.Mirror
+		}
+		info.FourScreen = game.FourScreen
+	}
+	info.Battery = game.Battery
+	if info.Battery && info.PRGNVRAMSize == 0 {
+		info.PRGNVRAMSize, info.PRGRAMSize = info.PRGRAMSize, 0
+	} else if !info.Battery && info.PRGNVRAMSize > 0 {
+		info.PRGRAMSize, info.PRGNVRAMSize = info.PRGRAMSize+info.PRGNVRAMSize, 0
+	}
+	info.Timing = game.Timing
+	info.Corrected = true
+}
+
+// Hash PRG and CHR ROM, ignoring the mirrored padding added by readROM.
+func hashROM(prg []uint8, prgSize int, chr []uint8, chrSize int) ROMHash {
+	if prgSize > len(prg) {
+		prgSize = len(prg)
+	}
+	if chrSize > len(chr) {
+		chrSize = len(chr)
+	}
+
+	crc := crc32.NewIEEE()
+	sha := sha1.New()
+	for _, data := range [][]uint8{prg[:prgSize], chr[:chrSize]} {
+		crc.Write(data)
+		sha.Write(data)
+	}
+
+	hash := ROMHash{CRC32: crc.Sum32()}
+	copy(hash.SHA1[:], sha.Sum(nil))
+	return hash
+}
+
+// Info Return board description read from the file header.
+func (cart *Cartridge) Info() CartridgeInfo {
+	return cart.info
+}
+
+// Hash Return hashes of PRG and CHR ROM.
+func (cart *Cartridge) Hash() ROMHash {
+	return cart.hash
+}
+
+// Trainer Return the 512 bytes trainer, nil if there's none.
+func (cart *Cartridge) Trainer() []uint8 {
+	return cart.trainer
+}
+
+// Patches Return patches applied when loading, in order.
+func (cart *Cartridge) Patches() []AppliedPatch {
+	return cart.patches
+}
+
+// Title Return game title from game database, or file name when the game is
+// unknown.
+func (cart *Cartridge) Title() string {
+	return cart.title
+}
+
+// Reset Reset cartridge to its power-up state.
+func (cart *Cartridge) Reset() {
+	cart.mapper.Reset()
+}
+
+// Clear volatile cartridge memory as if power was off, battery-backed RAM
+// keeps its contents. Trainer is loaded again either way.
+func (cart *Cartridge) powerOn() {
+	if !cart.battery {
+		for i := range cart.prgRAM {
+			cart.prgRAM[i] = 0
+		}
+	}
+	copy(cart.prgRAM[0x1000:], cart.trainer)
+	for i := range cart.vram {
+		cart.vram[i] = 0
+	}
+	if cart.chrBanks == 0 {
+		for i := range cart.chrMemory {
+			cart.chrMemory[i] = 0
+		}
+	}
+}
+
+// MD5 Return MD5 of PRG and CHR ROM, FCEUX movies identify ROMs by it.
+func (cart *Cartridge) MD5() [16]byte {
+	prgSize := cart.info.PRGROMSize
+	if prgSize > len(cart.prgMemory) {
+		prgSize = len(cart.prgMemory)
+	}
+	chrSize := cart.info.CHRROMSize
+	if chrSize > len(cart.chrMemory) {
+		chrSize = len(cart.chrMemory)
+	}
+
+	hash := md5.New()
+	hash.Write(cart.prgMemory[:prgSize])
+	hash.Write(cart.chrMemory[:chrSize])
+
+	var sum [16]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// Mirror Return current name table mirroring.
+func (cart *Cartridge) Mirror() int {
+	// Four-screen boards ignore mirroring control of their mapper.
+	if cart.mirror == mirrorFourScreen {
+		return cart.mirror
+	}
+
+	mirror := cart.mapper.Mirror()
+	if mirror == mirrorHardware {
+		return cart.mirror
+	}
+	return mirror
+}
+
+// NameTable Return 1KB memory backing name table 0-3 and whether PPU can write
+// it. vram is the console's own 2KB name table RAM.
+func (cart *Cartridge) NameTable(table uint16, vram *[2][1024]uint8) ([]uint8, bool) {
+	if cart.nameTableMapper != nil {
+		source, page := cart.nameTableMapper.MapNameTable(table)
+		switch source {
+		case NameTableCartridgeRAM:
+			if len(cart.vram) > 0 {
+				offset := (page * 1024) % uint32(len(cart.vram))
+				return cart.vram[offset : offset+1024], true
+			}
+		case NameTableCHR:
+			offset := (page * 1024) % uint32(len(cart.chrMemory))
+			return cart.chrMemory[offset : offset+1024], cart.chrBanks == 0
+		}
+		return vram[page&0x01][:], true
+	}
+
+	var page uint16
+	switch cart.Mirror() {
+	case mirrorHorizontal:
+		page = table >> 1
+	case mirrorVertical:
+		page = table & 0x01
+	case oneScreenLo:
+		page = 0
+	case oneScrennHi:
+		page = 1
+	case mirrorFourScreen:
+		if table >= 2 {
+			return cart.vram[(table-2)*1024 : (table-1)*1024], true
+		}
+		page = table
+	}
+	return vram[page][:], true
+}
+
+// IRQState Check if cartridge is pulling the IRQ line.
+func (cart *Cartridge) IRQState() bool {
+	if cart.irqSource != nil {
+		return cart.irqSource.IRQState()
+	}
+	return false
+}
+
+// PPUAddress Let mapper know what PPU is putting on its address bus.
+func (cart *Cartridge) PPUAddress(addr uint16) {
+	if cart.ppuWatcher != nil {
+		cart.ppuWatcher.PPUAddress(addr)
+	}
+}
+
+// CPUCycle Let mapper count a CPU cycle.
+func (cart *Cartridge) CPUCycle() {
+	if cart.cycleWatcher != nil {
+		cart.cycleWatcher.CPUCycle()
+	}
+}
+
+// Battery
+
+// Battery Check if cartridge keeps its PRG RAM with a battery.
+func (cart *Cartridge) Battery() bool {
+	return cart.battery
+}
+
+// SaveData Export a copy of battery-backed PRG RAM.
+func (cart *Cartridge) SaveData() []uint8 {
+	data := make([]uint8, len(cart.prgRAM))
+	copy(data, cart.prgRAM)
+	return data
+}
+
+// LoadSaveData Import battery-backed PRG RAM. Shorter data only overwrites the
+// beginning of RAM.
+func (cart *Cartridge) LoadSaveData(data []uint8) error {
+	if len(data) > len(cart.prgRAM) {
+		return fmt.Errorf("%w: %d bytes, PRG RAM only has %d", ErrSaveSize, len(data), len(cart.prgRAM))
+	}
+	copy(cart.prgRAM, data)
+	cart.prgRAMDirty = false
+	return nil
+}
+
+// Clear battery-backed RAM as if the battery had run out.
+func (cart *Cartridge) clearSaveRAM() {
+	for i := range cart.prgRAM {
+		cart.prgRAM[i] = 0
+	}
+	cart.prgRAMDirty = false
+}
+
+// SavePath Return path of the .sav file.
+func (cart *Cartridge) SavePath() string {
+	return cart.savePath
+}
+
+// SetSavePath Change path of the .sav file, does not load it. Empty path
+// turns saving off.
+func (cart *Cartridge) SetSavePath(path string) {
+	cart.savePath = path
+}
+
+// Save Write battery-backed PRG RAM to its .sav file if it changed since last
+// save. Does nothing for cartridges without battery.
+func (cart *Cartridge) Save() error {
+	if !cart.battery || !cart.prgRAMDirty || cart.savePath == "" {
+		return nil
+	}
+
+	// Write a temporary file first so a crash never leaves a torn save behind.
+	tmpPath := cart.savePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, cart.prgRAM, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, cart.savePath); err != nil {
+		return err
+	}
+
+	cart.prgRAMDirty = false
+	return nil
 }
 
 // CPU IO
@@ -116,7 +723,15 @@ func NewCartridge(filePath string) (*Cartridge, error) {
 func (cart *Cartridge) CPURead(addr uint16, data *uint8) bool {
 	var mappedAddr uint32 = 0
 	if cart.mapper.CPUMapRead(addr, &mappedAddr) {
-		*data = cart.prgMemory[mappedAddr]
+		if addr < 0x8000 {
+			*data = cart.prgRAM[mappedAddr]
+		} else {
+			*data = cart.prgMemory[mappedAddr]
+		}
+		return true
+	} else if cart.trainer != nil && addr >= 0x6000 && addr <= 0x7FFF {
+		// Boards without PRG RAM get it anyway to hold the trainer.
+		*data = cart.prgRAM[addr&0x1FFF]
 		return true
 	}
 
@@ -126,8 +741,31 @@ func (cart *Cartridge) CPURead(addr uint16, data *uint8) bool {
 // CPUWrite Check if cartridge handles CPU write.
 func (cart *Cartridge) CPUWrite(addr uint16, data uint8) bool {
 	var mappedAddr uint32 = 0
-	if cart.mapper.CPUMapWrite(addr, &mappedAddr) {
-		cart.prgMemory[mappedAddr] = data
+
+	// ROM and CPU both drive the bus, low bits win.
+	if cart.busConflicts && addr >= 0x8000 {
+		if cart.mapper.CPUMapRead(addr, &mappedAddr) {
+			data &= cart.prgMemory[mappedAddr]
+		}
+	}
+
+	if cart.mapper.CPUMapWrite(addr, &mappedAddr, data) {
+		if mappedAddr == mappedRegister {
+			// Mapper register, nothing to write.
+		} else if addr < 0x8000 {
+			if cart.prgRAM[mappedAddr] != data {
+				cart.prgRAMDirty = true
+			}
+			cart.prgRAM[mappedAddr] = data
+		} else {
+			cart.prgMemory[mappedAddr] = data
+		}
+		return true
+	} else if cart.trainer != nil && addr >= 0x6000 && addr <= 0x7FFF {
+		if cart.prgRAM[addr&0x1FFF] != data {
+			cart.prgRAMDirty = true
+		}
+		cart.prgRAM[addr&0x1FFF] = data
 		return true
 	}
 
@@ -0,0 +1,138 @@
+package nes
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCartridgeErrors Broken images fail with errors telling what went wrong.
+func TestCartridgeErrors(t *testing.T) {
+	rom := makeROM(0, 1, 1, 0, nil)
+
+	// NES 2.0 header with PRG ROM size in exponent notation, 2^63 bytes.
+	huge := append([]byte(nil), rom...)
+	huge[4] = 63 << 2
+	huge[7] = 0x08
+	huge[9] = 0x0F
+
+	withTrainer := append([]byte(nil), rom[:16]...)
+	withTrainer[6] |= 0x04
+	withTrainer = append(withTrainer, make([]byte, 100)...)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		section   string
+		err       error
+		truncated *TruncatedError
+	}{
+		{"empty", nil, "header", ErrTruncated, &TruncatedError{16, 0}},
+		{"short header", rom[:10], "header", ErrTruncated, &TruncatedError{16, 10}},
+		{"bad magic", append([]byte("NES!"), rom[4:]...), "header", ErrBadMagic, nil},
+		{"no PRG ROM", append(append([]byte(nil), rom[:4]...), append([]byte{0}, rom[5:]...)...), "PRG ROM", ErrBadSize, nil},
+		{"huge PRG ROM", huge, "PRG ROM", ErrBadSize, nil},
+		{"truncated trainer", withTrainer, "trainer", ErrTruncated, &TruncatedError{512, 100}},
+		{"truncated PRG ROM", rom[:16+1000], "PRG ROM", ErrTruncated, &TruncatedError{16384, 1000}},
+		{"truncated CHR ROM", rom[:len(rom)-1], "CHR ROM", ErrTruncated, &TruncatedError{8192, 8191}},
+		{"unsupported mapper", makeROM(0xF0, 1, 1, 0, nil), "mapper", ErrUnsupportedMapper, nil},
+	}
+
+	for _, test := range tests {
+		cart, err := NewCartridgeFromBytes(test.data)
+		if cart != nil || err == nil {
+			t.Errorf("%s: loaded without error", test.name)
+			continue
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, expected %v", test.name, err, test.err)
+		}
+
+		var romErr *ROMError
+		if !errors.As(err, &romErr) || romErr.Section != test.section {
+			t.Errorf("%s: got %v, expected ROMError of %s section", test.name, err, test.section)
+		}
+
+		var truncated *TruncatedError
+		if errors.As(err, &truncated) != (test.truncated != nil) {
+			t.Errorf("%s: got %v, TruncatedError expected %t", test.name, err, test.truncated != nil)
+		} else if test.truncated != nil && *truncated != *test.truncated {
+			t.Errorf("%s: got %+v, expected %+v", test.name, *truncated, *test.truncated)
+		}
+	}
+}
+
+// Image made by makeROM with a trainer of bytes counting up from 0.
+func makeTrainerROM(mapper uint8, prgBanks uint8, flags uint8) []byte {
+	rom := makeROM(mapper, prgBanks, 1, flags|0x04, nil)
+	trainer := make([]byte, 512)
+	for i := range trainer {
+		trainer[i] = uint8(i)
+	}
+	return append(rom[:16:16], append(trainer, rom[16:]...)...)
+}
+
+// TestTrainer Trainer shows at $7000 on boards with PRG RAM and on boards
+// without it.
+func TestTrainer(t *testing.T) {
+	for _, test := range []struct {
+		mapper   uint8
+		prgBanks uint8
+	}{{0, 1}, {1, 2}, {2, 2}, {3, 2}, {4, 2}, {7, 2}, {11, 2}, {66, 2}} {
+		bus, err := newTestBus(makeTrainerROM(test.mapper, test.prgBanks, 0))
+		if err != nil {
+			t.Fatalf("mapper %d: %v", test.mapper, err)
+		}
+		if data := bus.CPURead(0x7000, true); data != 0x00 {
+			t.Errorf("mapper %d: $7000 = $%02X, expected $00", test.mapper, data)
+		}
+		if data := bus.CPURead(0x71FF, true); data != 0xFF {
+			t.Errorf("mapper %d: $71FF = $%02X, expected $FF", test.mapper, data)
+		}
+		bus.CPUWrite(0x6000, 0x5A)
+		if data := bus.CPURead(0x6000, true); data != 0x5A {
+			t.Errorf("mapper %d: $6000 = $%02X after writing $5A", test.mapper, data)
+		}
+	}
+}
+
+// TestTrainerOverSave Trainer is loaded over the battery save, as it is at
+// power-up.
+func TestTrainerOverSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	save := make([]byte, 8192)
+	for i := range save {
+		save[i] = 0xAA
+	}
+	romPath := filepath.Join(dir, "trainer.nes")
+	if err := ioutil.WriteFile(romPath, makeTrainerROM(2, 2, 0x02), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "trainer.sav"), save, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := NewCartridge(romPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := NewBus()
+	bus.InsertCartridge(cart)
+	bus.Reset()
+	if data := bus.CPURead(0x6000, true); data != 0xAA {
+		t.Errorf("$6000 = $%02X, expected $AA from save", data)
+	}
+	if data := bus.CPURead(0x7001, true); data != 0x01 {
+		t.Errorf("$7001 = $%02X, expected $01 from trainer", data)
+	}
+	if data := bus.CPURead(0x7200, true); data != 0xAA {
+		t.Errorf("$7200 = $%02X, expected $AA from save", data)
+	}
+}
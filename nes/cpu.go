@@ -28,22 +28,22 @@ const (
 )
 
 var instructionModes = [256]byte{
-	2, 11, 1, 1, 1, 3, 3, 1, 1, 2, 1, 1, 1, 7, 7, 1,
-	6, 12, 1, 1, 1, 4, 4, 1, 1, 9, 1, 1, 1, 8, 8, 1,
-	7, 11, 1, 1, 3, 3, 3, 1, 1, 2, 1, 1, 7, 7, 7, 1,
-	6, 12, 1, 1, 1, 4, 4, 1, 1, 9, 1, 1, 1, 8, 8, 1,
-	1, 11, 1, 1, 1, 3, 3, 1, 1, 2, 1, 1, 7, 7, 7, 1,
-	6, 12, 1, 1, 1, 4, 4, 1, 1, 9, 1, 1, 1, 8, 8, 1,
-	1, 11, 1, 1, 1, 3, 3, 1, 1, 2, 1, 1, 10, 7, 7, 1,
-	6, 12, 1, 1, 1, 4, 4, 1, 1, 9, 1, 1, 1, 8, 8, 1,
-	1, 11, 1, 1, 3, 3, 3, 1, 1, 1, 1, 1, 7, 7, 7, 1,
-	6, 12, 1, 1, 4, 4, 5, 1, 1, 9, 1, 1, 1, 8, 1, 1,
-	2, 11, 2, 1, 3, 3, 3, 1, 1, 2, 1, 1, 7, 7, 7, 1,
-	6, 12, 1, 1, 4, 4, 5, 1, 1, 9, 1, 1, 8, 8, 9, 1,
-	2, 11, 1, 1, 3, 3, 3, 1, 1, 2, 1, 1, 7, 7, 7, 1,
-	6, 12, 1, 1, 1, 4, 4, 1, 1, 9, 1, 1, 1, 8, 8, 1,
-	2, 11, 1, 1, 3, 3, 3, 1, 1, 2, 1, 1, 7, 7, 7, 1,
-	6, 12, 1, 1, 1, 4, 4, 1, 1, 9, 1, 1, 1, 8, 8, 1,
+	2, 11, 1, 11, 3, 3, 3, 3, 1, 2, 1, 2, 7, 7, 7, 7,
+	6, 12, 1, 12, 4, 4, 4, 4, 1, 9, 1, 9, 8, 8, 8, 8,
+	7, 11, 1, 11, 3, 3, 3, 3, 1, 2, 1, 2, 7, 7, 7, 7,
+	6, 12, 1, 12, 4, 4, 4, 4, 1, 9, 1, 9, 8, 8, 8, 8,
+	1, 11, 1, 11, 3, 3, 3, 3, 1, 2, 1, 2, 7, 7, 7, 7,
+	6, 12, 1, 12, 4, 4, 4, 4, 1, 9, 1, 9, 8, 8, 8, 8,
+	1, 11, 1, 11, 3, 3, 3, 3, 1, 2, 1, 2, 10, 7, 7, 7,
+	6, 12, 1, 12, 4, 4, 4, 4, 1, 9, 1, 9, 8, 8, 8, 8,
+	2, 11, 2, 11, 3, 3, 3, 3, 1, 2, 1, 2, 7, 7, 7, 7,
+	6, 12, 1, 12, 4, 4, 5, 5, 1, 9, 1, 9, 8, 8, 9, 9,
+	2, 11, 2, 11, 3, 3, 3, 3, 1, 2, 1, 2, 7, 7, 7, 7,
+	6, 12, 1, 12, 4, 4, 5, 5, 1, 9, 1, 9, 8, 8, 9, 9,
+	2, 11, 2, 11, 3, 3, 3, 3, 1, 2, 1, 2, 7, 7, 7, 7,
+	6, 12, 1, 12, 4, 4, 4, 4, 1, 9, 1, 9, 8, 8, 8, 8,
+	2, 11, 2, 11, 3, 3, 3, 3, 1, 2, 1, 2, 7, 7, 7, 7,
+	6, 12, 1, 12, 4, 4, 4, 4, 1, 9, 1, 9, 8, 8, 8, 8,
 }
 
 var instructionCycles = [256]byte{
@@ -65,24 +65,24 @@ var instructionCycles = [256]byte{
 	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
 }
 
-// "XXX" means illegal instructions.
+// Names of unofficial instructions start with "*".
 var instructionNames = [256]string{
-	"BRK", "ORA", "XXX", "XXX", "NOP", "ORA", "ASL", "XXX", "PHP", "ORA", "ASL", "XXX", "NOP", "ORA", "ASL", "XXX",
-	"BPL", "ORA", "XXX", "XXX", "NOP", "ORA", "ASL", "XXX", "CLC", "ORA", "NOP", "XXX", "NOP", "ORA", "ASL", "XXX",
-	"JSR", "AND", "XXX", "XXX", "BIT", "AND", "ROL", "XXX", "PLP", "AND", "ROL", "XXX", "BIT", "AND", "ROL", "XXX",
-	"BMI", "AND", "XXX", "XXX", "NOP", "AND", "ROL", "XXX", "SEC", "AND", "NOP", "XXX", "NOP", "AND", "ROL", "XXX",
-	"RTI", "EOR", "XXX", "XXX", "NOP", "EOR", "LSR", "XXX", "PHA", "EOR", "LSR", "XXX", "JMP", "EOR", "LSR", "XXX",
-	"BVC", "EOR", "XXX", "XXX", "NOP", "EOR", "LSR", "XXX", "CLI", "EOR", "NOP", "XXX", "NOP", "EOR", "LSR", "XXX",
-	"RTS", "ADC", "XXX", "XXX", "NOP", "ADC", "ROR", "XXX", "PLA", "ADC", "ROR", "XXX", "JMP", "ADC", "ROR", "XXX",
-	"BVS", "ADC", "XXX", "XXX", "NOP", "ADC", "ROR", "XXX", "SEI", "ADC", "NOP", "XXX", "NOP", "ADC", "ROR", "XXX",
-	"NOP", "STA", "NOP", "XXX", "STY", "STA", "STX", "XXX", "DEY", "NOP", "TXA", "XXX", "STY", "STA", "STX", "XXX",
-	"BCC", "STA", "XXX", "XXX", "STY", "STA", "STX", "XXX", "TYA", "STA", "TXS", "XXX", "NOP", "STA", "XXX", "XXX",
-	"LDY", "LDA", "LDX", "XXX", "LDY", "LDA", "LDX", "XXX", "TAY", "LDA", "TAX", "XXX", "LDY", "LDA", "LDX", "XXX",
-	"BCS", "LDA", "XXX", "XXX", "LDY", "LDA", "LDX", "XXX", "CLV", "LDA", "TSX", "XXX", "LDY", "LDA", "LDX", "XXX",
-	"CPY", "CMP", "NOP", "XXX", "CPY", "CMP", "DEC", "XXX", "INY", "CMP", "DEX", "XXX", "CPY", "CMP", "DEC", "XXX",
-	"BNE", "CMP", "XXX", "XXX", "NOP", "CMP", "DEC", "XXX", "CLD", "CMP", "NOP", "XXX", "NOP", "CMP", "DEC", "XXX",
-	"CPX", "SBC", "NOP", "XXX", "CPX", "SBC", "INC", "XXX", "INX", "SBC", "NOP", "SBC", "CPX", "SBC", "INC", "XXX",
-	"BEQ", "SBC", "XXX", "XXX", "NOP", "SBC", "INC", "XXX", "SED", "SBC", "NOP", "XXX", "NOP", "SBC", "INC", "XXX",
+	"BRK", "ORA", "*KIL", "*SLO", "*NOP", "ORA", "ASL", "*SLO", "PHP", "ORA", "ASL", "*ANC", "*NOP", "ORA", "ASL", "*SLO",
+	"BPL", "ORA", "*KIL", "*SLO", "*NOP", "ORA", "ASL", "*SLO", "CLC", "ORA", "*NOP", "*SLO", "*NOP", "ORA", "ASL", "*SLO",
+	"JSR", "AND", "*KIL", "*RLA", "BIT", "AND", "ROL", "*RLA", "PLP", "AND", "ROL", "*ANC", "BIT", "AND", "ROL", "*RLA",
+	"BMI", "AND", "*KIL", "*RLA", "*NOP", "AND", "ROL", "*RLA", "SEC", "AND", "*NOP", "*RLA", "*NOP", "AND", "ROL", "*RLA",
+	"RTI", "EOR", "*KIL", "*SRE", "*NOP", "EOR", "LSR", "*SRE", "PHA", "EOR", "LSR", "*ALR", "JMP", "EOR", "LSR", "*SRE",
+	"BVC", "EOR", "*KIL", "*SRE", "*NOP", "EOR", "LSR", "*SRE", "CLI", "EOR", "*NOP", "*SRE", "*NOP", "EOR", "LSR", "*SRE",
+	"RTS", "ADC", "*KIL", "*RRA", "*NOP", "ADC", "ROR", "*RRA", "PLA", "ADC", "ROR", "*ARR", "JMP", "ADC", "ROR", "*RRA",
+	"BVS", "ADC", "*KIL", "*RRA", "*NOP", "ADC", "ROR", "*RRA", "SEI", "ADC", "*NOP", "*RRA", "*NOP", "ADC", "ROR", "*RRA",
+	"*NOP", "STA", "*NOP", "*SAX", "STY", "STA", "STX", "*SAX", "DEY", "*NOP", "TXA", "*XAA", "STY", "STA", "STX", "*SAX",
+	"BCC", "STA", "*KIL", "*AHX", "STY", "STA", "STX", "*SAX", "TYA", "STA", "TXS", "*TAS", "*SHY", "STA", "*SHX", "*AHX",
+	"LDY", "LDA", "LDX", "*LAX", "LDY", "LDA", "LDX", "*LAX", "TAY", "LDA", "TAX", "*LAX", "LDY", "LDA", "LDX", "*LAX",
+	"BCS", "LDA", "*KIL", "*LAX", "LDY", "LDA", "LDX", "*LAX", "CLV", "LDA", "TSX", "*LAS", "LDY", "LDA", "LDX", "*LAX",
+	"CPY", "CMP", "*NOP", "*DCP", "CPY", "CMP", "DEC", "*DCP", "INY", "CMP", "DEX", "*AXS", "CPY", "CMP", "DEC", "*DCP",
+	"BNE", "CMP", "*KIL", "*DCP", "*NOP", "CMP", "DEC", "*DCP", "CLD", "CMP", "*NOP", "*DCP", "*NOP", "CMP", "DEC", "*DCP",
+	"CPX", "SBC", "*NOP", "*ISC", "CPX", "SBC", "INC", "*ISC", "INX", "SBC", "NOP", "*SBC", "CPX", "SBC", "INC", "*ISC",
+	"BEQ", "SBC", "*KIL", "*ISC", "*NOP", "SBC", "INC", "*ISC", "SED", "SBC", "*NOP", "*ISC", "*NOP", "SBC", "INC", "*ISC",
 }
 
 // type Instruction struct {
@@ -112,6 +112,7 @@ type CPU struct {
 	opcode     uint8
 	cycles     uint8
 	clockCount uint32
+	halted     bool // Jammed by KIL until reset.
 
 	opcodeTable [256]func() uint8
 	modeTable   [256]func() uint8
@@ -119,41 +120,41 @@ type CPU struct {
 
 func (cpu *CPU) createTable() {
 	cpu.opcodeTable = [256]func() uint8{
-		cpu.brk, cpu.ora, cpu.xxx, cpu.xxx, cpu.nop, cpu.ora, cpu.asl, cpu.xxx, cpu.php, cpu.ora, cpu.asl, cpu.xxx, cpu.nop, cpu.ora, cpu.asl, cpu.xxx,
-		cpu.bpl, cpu.ora, cpu.xxx, cpu.xxx, cpu.nop, cpu.ora, cpu.asl, cpu.xxx, cpu.clc, cpu.ora, cpu.nop, cpu.xxx, cpu.nop, cpu.ora, cpu.asl, cpu.xxx,
-		cpu.jsr, cpu.and, cpu.xxx, cpu.xxx, cpu.bit, cpu.and, cpu.rol, cpu.xxx, cpu.plp, cpu.and, cpu.rol, cpu.xxx, cpu.bit, cpu.and, cpu.rol, cpu.xxx,
-		cpu.bmi, cpu.and, cpu.xxx, cpu.xxx, cpu.nop, cpu.and, cpu.rol, cpu.xxx, cpu.sec, cpu.and, cpu.nop, cpu.xxx, cpu.nop, cpu.and, cpu.rol, cpu.xxx,
-		cpu.rti, cpu.eor, cpu.xxx, cpu.xxx, cpu.nop, cpu.eor, cpu.lsr, cpu.xxx, cpu.pha, cpu.eor, cpu.lsr, cpu.xxx, cpu.jmp, cpu.eor, cpu.lsr, cpu.xxx,
-		cpu.bvc, cpu.eor, cpu.xxx, cpu.xxx, cpu.nop, cpu.eor, cpu.lsr, cpu.xxx, cpu.cli, cpu.eor, cpu.nop, cpu.xxx, cpu.nop, cpu.eor, cpu.lsr, cpu.xxx,
-		cpu.rts, cpu.adc, cpu.xxx, cpu.xxx, cpu.nop, cpu.adc, cpu.ror, cpu.xxx, cpu.pla, cpu.adc, cpu.ror, cpu.xxx, cpu.jmp, cpu.adc, cpu.ror, cpu.xxx,
-		cpu.bvs, cpu.adc, cpu.xxx, cpu.xxx, cpu.nop, cpu.adc, cpu.ror, cpu.xxx, cpu.sei, cpu.adc, cpu.nop, cpu.xxx, cpu.nop, cpu.adc, cpu.ror, cpu.xxx,
-		cpu.nop, cpu.sta, cpu.nop, cpu.xxx, cpu.sty, cpu.sta, cpu.stx, cpu.xxx, cpu.dey, cpu.nop, cpu.txa, cpu.xxx, cpu.sty, cpu.sta, cpu.stx, cpu.xxx,
-		cpu.bcc, cpu.sta, cpu.xxx, cpu.xxx, cpu.sty, cpu.sta, cpu.stx, cpu.xxx, cpu.tya, cpu.sta, cpu.txs, cpu.xxx, cpu.nop, cpu.sta, cpu.xxx, cpu.xxx,
-		cpu.ldy, cpu.lda, cpu.ldx, cpu.xxx, cpu.ldy, cpu.lda, cpu.ldx, cpu.xxx, cpu.tay, cpu.lda, cpu.tax, cpu.xxx, cpu.ldy, cpu.lda, cpu.ldx, cpu.xxx,
-		cpu.bcs, cpu.lda, cpu.xxx, cpu.xxx, cpu.ldy, cpu.lda, cpu.ldx, cpu.xxx, cpu.clv, cpu.lda, cpu.tsx, cpu.xxx, cpu.ldy, cpu.lda, cpu.ldx, cpu.xxx,
-		cpu.cpy, cpu.cmp, cpu.nop, cpu.xxx, cpu.cpy, cpu.cmp, cpu.dec, cpu.xxx, cpu.iny, cpu.cmp, cpu.dex, cpu.xxx, cpu.cpy, cpu.cmp, cpu.dec, cpu.xxx,
-		cpu.bne, cpu.cmp, cpu.xxx, cpu.xxx, cpu.nop, cpu.cmp, cpu.dec, cpu.xxx, cpu.cld, cpu.cmp, cpu.nop, cpu.xxx, cpu.nop, cpu.cmp, cpu.dec, cpu.xxx,
-		cpu.cpx, cpu.sbc, cpu.nop, cpu.xxx, cpu.cpx, cpu.sbc, cpu.inc, cpu.xxx, cpu.inx, cpu.sbc, cpu.nop, cpu.sbc, cpu.cpx, cpu.sbc, cpu.inc, cpu.xxx,
-		cpu.beq, cpu.sbc, cpu.xxx, cpu.xxx, cpu.nop, cpu.sbc, cpu.inc, cpu.xxx, cpu.sed, cpu.sbc, cpu.nop, cpu.xxx, cpu.nop, cpu.sbc, cpu.inc, cpu.xxx,
+		cpu.brk, cpu.ora, cpu.kil, cpu.slo, cpu.nop, cpu.ora, cpu.asl, cpu.slo, cpu.php, cpu.ora, cpu.asl, cpu.anc, cpu.nop, cpu.ora, cpu.asl, cpu.slo,
+		cpu.bpl, cpu.ora, cpu.kil, cpu.slo, cpu.nop, cpu.ora, cpu.asl, cpu.slo, cpu.clc, cpu.ora, cpu.nop, cpu.slo, cpu.nop, cpu.ora, cpu.asl, cpu.slo,
+		cpu.jsr, cpu.and, cpu.kil, cpu.rla, cpu.bit, cpu.and, cpu.rol, cpu.rla, cpu.plp, cpu.and, cpu.rol, cpu.anc, cpu.bit, cpu.and, cpu.rol, cpu.rla,
+		cpu.bmi, cpu.and, cpu.kil, cpu.rla, cpu.nop, cpu.and, cpu.rol, cpu.rla, cpu.sec, cpu.and, cpu.nop, cpu.rla, cpu.nop, cpu.and, cpu.rol, cpu.rla,
+		cpu.rti, cpu.eor, cpu.kil, cpu.sre, cpu.nop, cpu.eor, cpu.lsr, cpu.sre, cpu.pha, cpu.eor, cpu.lsr, cpu.alr, cpu.jmp, cpu.eor, cpu.lsr, cpu.sre,
+		cpu.bvc, cpu.eor, cpu.kil, cpu.sre, cpu.nop, cpu.eor, cpu.lsr, cpu.sre, cpu.cli, cpu.eor, cpu.nop, cpu.sre, cpu.nop, cpu.eor, cpu.lsr, cpu.sre,
+		cpu.rts, cpu.adc, cpu.kil, cpu.rra, cpu.nop, cpu.adc, cpu.ror, cpu.rra, cpu.pla, cpu.adc, cpu.ror, cpu.arr, cpu.jmp, cpu.adc, cpu.ror, cpu.rra,
+		cpu.bvs, cpu.adc, cpu.kil, cpu.rra, cpu.nop, cpu.adc, cpu.ror, cpu.rra, cpu.sei, cpu.adc, cpu.nop, cpu.rra, cpu.nop, cpu.adc, cpu.ror, cpu.rra,
+		cpu.nop, cpu.sta, cpu.nop, cpu.sax, cpu.sty, cpu.sta, cpu.stx, cpu.sax, cpu.dey, cpu.nop, cpu.txa, cpu.xaa, cpu.sty, cpu.sta, cpu.stx, cpu.sax,
+		cpu.bcc, cpu.sta, cpu.kil, cpu.ahx, cpu.sty, cpu.sta, cpu.stx, cpu.sax, cpu.tya, cpu.sta, cpu.txs, cpu.tas, cpu.shy, cpu.sta, cpu.shx, cpu.ahx,
+		cpu.ldy, cpu.lda, cpu.ldx, cpu.lax, cpu.ldy, cpu.lda, cpu.ldx, cpu.lax, cpu.tay, cpu.lda, cpu.tax, cpu.lax, cpu.ldy, cpu.lda, cpu.ldx, cpu.lax,
+		cpu.bcs, cpu.lda, cpu.kil, cpu.lax, cpu.ldy, cpu.lda, cpu.ldx, cpu.lax, cpu.clv, cpu.lda, cpu.tsx, cpu.las, cpu.ldy, cpu.lda, cpu.ldx, cpu.lax,
+		cpu.cpy, cpu.cmp, cpu.nop, cpu.dcp, cpu.cpy, cpu.cmp, cpu.dec, cpu.dcp, cpu.iny, cpu.cmp, cpu.dex, cpu.axs, cpu.cpy, cpu.cmp, cpu.dec, cpu.dcp,
+		cpu.bne, cpu.cmp, cpu.kil, cpu.dcp, cpu.nop, cpu.cmp, cpu.dec, cpu.dcp, cpu.cld, cpu.cmp, cpu.nop, cpu.dcp, cpu.nop, cpu.cmp, cpu.dec, cpu.dcp,
+		cpu.cpx, cpu.sbc, cpu.nop, cpu.isc, cpu.cpx, cpu.sbc, cpu.inc, cpu.isc, cpu.inx, cpu.sbc, cpu.nop, cpu.sbc, cpu.cpx, cpu.sbc, cpu.inc, cpu.isc,
+		cpu.beq, cpu.sbc, cpu.kil, cpu.isc, cpu.nop, cpu.sbc, cpu.inc, cpu.isc, cpu.sed, cpu.sbc, cpu.nop, cpu.isc, cpu.nop, cpu.sbc, cpu.inc, cpu.isc,
 	}
 
 	cpu.modeTable = [256]func() uint8{
-		cpu.imm, cpu.izx, cpu.imp, cpu.imp, cpu.imp, cpu.zp0, cpu.zp0, cpu.imp, cpu.imp, cpu.imm, cpu.imp, cpu.imp, cpu.imp, cpu.abs, cpu.abs, cpu.imp,
-		cpu.rel, cpu.izy, cpu.imp, cpu.imp, cpu.imp, cpu.zpx, cpu.zpx, cpu.imp, cpu.imp, cpu.aby, cpu.imp, cpu.imp, cpu.imp, cpu.abx, cpu.abx, cpu.imp,
-		cpu.abs, cpu.izx, cpu.imp, cpu.imp, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imp, cpu.imm, cpu.imp, cpu.imp, cpu.abs, cpu.abs, cpu.abs, cpu.imp,
-		cpu.rel, cpu.izy, cpu.imp, cpu.imp, cpu.imp, cpu.zpx, cpu.zpx, cpu.imp, cpu.imp, cpu.aby, cpu.imp, cpu.imp, cpu.imp, cpu.abx, cpu.abx, cpu.imp,
-		cpu.imp, cpu.izx, cpu.imp, cpu.imp, cpu.imp, cpu.zp0, cpu.zp0, cpu.imp, cpu.imp, cpu.imm, cpu.imp, cpu.imp, cpu.abs, cpu.abs, cpu.abs, cpu.imp,
-		cpu.rel, cpu.izy, cpu.imp, cpu.imp, cpu.imp, cpu.zpx, cpu.zpx, cpu.imp, cpu.imp, cpu.aby, cpu.imp, cpu.imp, cpu.imp, cpu.abx, cpu.abx, cpu.imp,
-		cpu.imp, cpu.izx, cpu.imp, cpu.imp, cpu.imp, cpu.zp0, cpu.zp0, cpu.imp, cpu.imp, cpu.imm, cpu.imp, cpu.imp, cpu.ind, cpu.abs, cpu.abs, cpu.imp,
-		cpu.rel, cpu.izy, cpu.imp, cpu.imp, cpu.imp, cpu.zpx, cpu.zpx, cpu.imp, cpu.imp, cpu.aby, cpu.imp, cpu.imp, cpu.imp, cpu.abx, cpu.abx, cpu.imp,
-		cpu.imp, cpu.izx, cpu.imp, cpu.imp, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imp, cpu.imp, cpu.imp, cpu.imp, cpu.abs, cpu.abs, cpu.abs, cpu.imp,
-		cpu.rel, cpu.izy, cpu.imp, cpu.imp, cpu.zpx, cpu.zpx, cpu.zpy, cpu.imp, cpu.imp, cpu.aby, cpu.imp, cpu.imp, cpu.imp, cpu.abx, cpu.imp, cpu.imp,
-		cpu.imm, cpu.izx, cpu.imm, cpu.imp, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imp, cpu.imm, cpu.imp, cpu.imp, cpu.abs, cpu.abs, cpu.abs, cpu.imp,
-		cpu.rel, cpu.izy, cpu.imp, cpu.imp, cpu.zpx, cpu.zpx, cpu.zpy, cpu.imp, cpu.imp, cpu.aby, cpu.imp, cpu.imp, cpu.abx, cpu.abx, cpu.aby, cpu.imp,
-		cpu.imm, cpu.izx, cpu.imp, cpu.imp, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imp, cpu.imm, cpu.imp, cpu.imp, cpu.abs, cpu.abs, cpu.abs, cpu.imp,
-		cpu.rel, cpu.izy, cpu.imp, cpu.imp, cpu.imp, cpu.zpx, cpu.zpx, cpu.imp, cpu.imp, cpu.aby, cpu.imp, cpu.imp, cpu.imp, cpu.abx, cpu.abx, cpu.imp,
-		cpu.imm, cpu.izx, cpu.imp, cpu.imp, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imp, cpu.imm, cpu.imp, cpu.imp, cpu.abs, cpu.abs, cpu.abs, cpu.imp,
-		cpu.rel, cpu.izy, cpu.imp, cpu.imp, cpu.imp, cpu.zpx, cpu.zpx, cpu.imp, cpu.imp, cpu.aby, cpu.imp, cpu.imp, cpu.imp, cpu.abx, cpu.abx, cpu.imp,
+		cpu.imm, cpu.izx, cpu.imp, cpu.izx, cpu.zp0, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imm, cpu.imp, cpu.imm, cpu.abs, cpu.abs, cpu.abs, cpu.abs,
+		cpu.rel, cpu.izy, cpu.imp, cpu.izy, cpu.zpx, cpu.zpx, cpu.zpx, cpu.zpx, cpu.imp, cpu.aby, cpu.imp, cpu.aby, cpu.abx, cpu.abx, cpu.abx, cpu.abx,
+		cpu.abs, cpu.izx, cpu.imp, cpu.izx, cpu.zp0, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imm, cpu.imp, cpu.imm, cpu.abs, cpu.abs, cpu.abs, cpu.abs,
+		cpu.rel, cpu.izy, cpu.imp, cpu.izy, cpu.zpx, cpu.zpx, cpu.zpx, cpu.zpx, cpu.imp, cpu.aby, cpu.imp, cpu.aby, cpu.abx, cpu.abx, cpu.abx, cpu.abx,
+		cpu.imp, cpu.izx, cpu.imp, cpu.izx, cpu.zp0, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imm, cpu.imp, cpu.imm, cpu.abs, cpu.abs, cpu.abs, cpu.abs,
+		cpu.rel, cpu.izy, cpu.imp, cpu.izy, cpu.zpx, cpu.zpx, cpu.zpx, cpu.zpx, cpu.imp, cpu.aby, cpu.imp, cpu.aby, cpu.abx, cpu.abx, cpu.abx, cpu.abx,
+		cpu.imp, cpu.izx, cpu.imp, cpu.izx, cpu.zp0, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imm, cpu.imp, cpu.imm, cpu.ind, cpu.abs, cpu.abs, cpu.abs,
+		cpu.rel, cpu.izy, cpu.imp, cpu.izy, cpu.zpx, cpu.zpx, cpu.zpx, cpu.zpx, cpu.imp, cpu.aby, cpu.imp, cpu.aby, cpu.abx, cpu.abx, cpu.abx, cpu.abx,
+		cpu.imm, cpu.izx, cpu.imm, cpu.izx, cpu.zp0, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imm, cpu.imp, cpu.imm, cpu.abs, cpu.abs, cpu.abs, cpu.abs,
+		cpu.rel, cpu.izy, cpu.imp, cpu.izy, cpu.zpx, cpu.zpx, cpu.zpy, cpu.zpy, cpu.imp, cpu.aby, cpu.imp, cpu.aby, cpu.abx, cpu.abx, cpu.aby, cpu.aby,
+		cpu.imm, cpu.izx, cpu.imm, cpu.izx, cpu.zp0, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imm, cpu.imp, cpu.imm, cpu.abs, cpu.abs, cpu.abs, cpu.abs,
+		cpu.rel, cpu.izy, cpu.imp, cpu.izy, cpu.zpx, cpu.zpx, cpu.zpy, cpu.zpy, cpu.imp, cpu.aby, cpu.imp, cpu.aby, cpu.abx, cpu.abx, cpu.aby, cpu.aby,
+		cpu.imm, cpu.izx, cpu.imm, cpu.izx, cpu.zp0, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imm, cpu.imp, cpu.imm, cpu.abs, cpu.abs, cpu.abs, cpu.abs,
+		cpu.rel, cpu.izy, cpu.imp, cpu.izy, cpu.zpx, cpu.zpx, cpu.zpx, cpu.zpx, cpu.imp, cpu.aby, cpu.imp, cpu.aby, cpu.abx, cpu.abx, cpu.abx, cpu.abx,
+		cpu.imm, cpu.izx, cpu.imm, cpu.izx, cpu.zp0, cpu.zp0, cpu.zp0, cpu.zp0, cpu.imp, cpu.imm, cpu.imp, cpu.imm, cpu.abs, cpu.abs, cpu.abs, cpu.abs,
+		cpu.rel, cpu.izy, cpu.imp, cpu.izy, cpu.zpx, cpu.zpx, cpu.zpx, cpu.zpx, cpu.imp, cpu.aby, cpu.imp, cpu.aby, cpu.abx, cpu.abx, cpu.abx, cpu.abx,
 	}
 }
 
@@ -177,14 +178,17 @@ func (cpu *CPU) Reset() {
 	cpu.X = 0
 	cpu.Y = 0
 	cpu.SP = 0xFD
-	cpu.Status = 0x00 | flagUnused
+	// Interrupts are disabled on reset, otherwise APU frame IRQ fires before
+	// the game gets a chance to set things up.
+	cpu.Status = 0x00 | flagUnused | flagDisableInterrupts
 
 	cpu.addrAbs = 0x0000
 	cpu.addrRel = 0x0000
 	cpu.fetched = 0x00
+	cpu.halted = false
 
-	// Interrupt reset need cycles.
-	cpu.cycles = 8
+	// Reset sequence takes 7 cycles.
+	cpu.cycles = 7
 }
 
 // IO
@@ -197,6 +201,14 @@ func (cpu *CPU) write(addr uint16, data uint8) {
 	cpu.Bus.CPUWrite(addr, data)
 }
 
+// Write result of a read-modify-write instruction. Real 6502 writes the
+// unmodified value back first, on the cycle before the result, and mappers
+// such as MMC1 react to that dummy write.
+func (cpu *CPU) writeModified(addr uint16, data uint8) {
+	cpu.write(addr, cpu.fetched)
+	cpu.write(addr, data)
+}
+
 func (cpu *CPU) getFlag(f uint8) uint8 {
 	if (cpu.Status & f) > 0 {
 		return 1
@@ -215,6 +227,11 @@ func (cpu *CPU) setFlag(f uint8, v bool) {
 
 // Clock Tick CPU once.
 func (cpu *CPU) Clock() {
+	if cpu.halted {
+		cpu.clockCount++
+		return
+	}
+
 	if cpu.cycles == 0 {
 		cpu.opcode = cpu.read(cpu.PC)
 
@@ -240,7 +257,7 @@ func (cpu *CPU) Clock() {
 
 // IRQ Interrupt request
 func (cpu *CPU) IRQ() {
-	if cpu.getFlag(flagDisableInterrupts) == 0 {
+	if cpu.getFlag(flagDisableInterrupts) == 0 && !cpu.halted {
 		cpu.write(0x0100+uint16(cpu.SP), uint8((cpu.PC>>8)&0x00FF))
 		cpu.SP--
 		cpu.write(0x0100+uint16(cpu.SP), uint8(cpu.PC&0x00FF))
@@ -263,6 +280,10 @@ func (cpu *CPU) IRQ() {
 
 // NMI Non-maskable interrupt
 func (cpu *CPU) NMI() {
+	if cpu.halted {
+		return
+	}
+
 	cpu.write(0x0100+uint16(cpu.SP), uint8((cpu.PC>>8)&0x00FF))
 	cpu.SP--
 	cpu.write(0x0100+uint16(cpu.SP), uint8(cpu.PC&0x00FF))
@@ -421,34 +442,29 @@ func (cpu *CPU) fetch() uint8 {
 	return cpu.fetched
 }
 
-// Legal instructions
-func (cpu *CPU) adc() uint8 {
-	cpu.fetch()
-
-	cpu.temp = uint16(cpu.A) + uint16(cpu.fetched) + uint16(cpu.getFlag(flagCarryBit))
+// A = A + value + C, shared by ADC, SBC, RRA and ISC.
+func (cpu *CPU) addWithCarry(value uint8) {
+	cpu.temp = uint16(cpu.A) + uint16(value) + uint16(cpu.getFlag(flagCarryBit))
 
 	cpu.setFlag(flagCarryBit, cpu.temp > 255)
 	cpu.setFlag(flagZero, (cpu.temp&0x00FF) == 0)
 	// V = (A ^ R) & ~(A ^ M)
-	cpu.setFlag(flagOverflow, (^(uint16(cpu.A)^uint16(cpu.fetched))&(uint16(cpu.A)^uint16(cpu.temp)))&0x0080 != 0)
+	cpu.setFlag(flagOverflow, (^(uint16(cpu.A)^uint16(value))&(uint16(cpu.A)^uint16(cpu.temp)))&0x0080 != 0)
 	cpu.setFlag(flagNegative, cpu.temp&0x80 != 0)
 	cpu.A = uint8(cpu.temp & 0x00FF)
+}
 
+// Legal instructions
+func (cpu *CPU) adc() uint8 {
+	cpu.fetch()
+	cpu.addWithCarry(cpu.fetched)
 	return 1
 }
 
 func (cpu *CPU) sbc() uint8 {
 	cpu.fetch()
-
-	var value uint16 = (uint16(cpu.fetched)) ^ 0x00FF
-
-	cpu.temp = uint16(cpu.A) + value + uint16(cpu.getFlag(flagCarryBit))
-	cpu.setFlag(flagCarryBit, cpu.temp&0xFF00 != 0)
-	cpu.setFlag(flagZero, (cpu.temp&0x00FF) == 0)
-	cpu.setFlag(flagOverflow, (cpu.temp^uint16(cpu.A))&(cpu.temp^value)&0x0080 != 0)
-	cpu.setFlag(flagNegative, cpu.temp&0x0080 != 0)
-	cpu.A = uint8(cpu.temp & 0x00FF)
-
+	// Subtraction is addition of the inverted value.
+	cpu.addWithCarry(cpu.fetched ^ 0xFF)
 	return 1
 }
 
@@ -470,7 +486,7 @@ func (cpu *CPU) asl() uint8 {
 	if instructionModes[cpu.opcode] == modeImplied {
 		cpu.A = uint8(cpu.temp & 0x00FF)
 	} else {
-		cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
+		cpu.writeModified(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	}
 
 	return 0
@@ -645,7 +661,7 @@ func (cpu *CPU) cmp() uint8 {
 	cpu.fetch()
 	cpu.temp = uint16(cpu.A) - uint16(cpu.fetched)
 	cpu.setFlag(flagCarryBit, cpu.A >= cpu.fetched)
-	cpu.setFlag(flagZero, (cpu.temp*0x00FF) == 0x0000)
+	cpu.setFlag(flagZero, (cpu.temp&0x00FF) == 0x0000)
 	cpu.setFlag(flagNegative, cpu.temp&0x0080 != 0)
 	return 1
 }
@@ -671,7 +687,7 @@ func (cpu *CPU) cpy() uint8 {
 func (cpu *CPU) dec() uint8 {
 	cpu.fetch()
 	cpu.temp = uint16(cpu.fetched - 1)
-	cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
+	cpu.writeModified(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	cpu.setFlag(flagZero, (cpu.temp&0x00FF) == 0x0000)
 	cpu.setFlag(flagNegative, cpu.temp&0x0080 != 0)
 	return 0
@@ -702,7 +718,7 @@ func (cpu *CPU) eor() uint8 {
 func (cpu *CPU) inc() uint8 {
 	cpu.fetch()
 	cpu.temp = uint16(cpu.fetched + 1)
-	cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
+	cpu.writeModified(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	cpu.setFlag(flagZero, (cpu.temp&0x00FF) == 0x0000)
 	cpu.setFlag(flagNegative, cpu.temp&0x0080 != 0)
 	return 0
@@ -772,20 +788,17 @@ func (cpu *CPU) lsr() uint8 {
 	if instructionModes[cpu.opcode] == modeImplied {
 		cpu.A = uint8(cpu.temp & 0x00FF)
 	} else {
-		cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
+		cpu.writeModified(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	}
 	return 0
 }
 
 func (cpu *CPU) nop() uint8 {
-	// Note that not all NOPs are equal. There're indeed some illegal ones.
+	// Note that not all NOPs are equal. Unofficial ones with an operand still
+	// read it, and the absolute,X ones take the page crossing penalty.
+	cpu.fetch()
 	switch cpu.opcode {
-	case 0x1C:
-	case 0x3C:
-	case 0x5C:
-	case 0x7C:
-	case 0xDC:
-	case 0xFC:
+	case 0x1C, 0x3C, 0x5C, 0x7C, 0xDC, 0xFC:
 		return 1
 	}
 	return 0
@@ -823,7 +836,8 @@ func (cpu *CPU) pla() uint8 {
 
 func (cpu *CPU) plp() uint8 {
 	cpu.SP++
-	cpu.Status = cpu.read(0x0100 + uint16(cpu.SP))
+	// Break flag only exists on the stack, ignore it.
+	cpu.Status = cpu.read(0x0100+uint16(cpu.SP)) &^ flagBreak
 	cpu.setFlag(flagUnused, true)
 	return 0
 }
@@ -837,7 +851,7 @@ func (cpu *CPU) rol() uint8 {
 	if instructionModes[cpu.opcode] == modeImplied {
 		cpu.A = uint8(cpu.temp & 0x00FF)
 	} else {
-		cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
+		cpu.writeModified(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	}
 	return 0
 }
@@ -851,7 +865,7 @@ func (cpu *CPU) ror() uint8 {
 	if instructionModes[cpu.opcode] == modeImplied {
 		cpu.A = uint8(cpu.temp & 0x00FF)
 	} else {
-		cpu.write(cpu.addrAbs, uint8(cpu.temp&0x00FF))
+		cpu.writeModified(cpu.addrAbs, uint8(cpu.temp&0x00FF))
 	}
 	return 0
 }
@@ -949,87 +963,201 @@ func (cpu *CPU) tya() uint8 {
 	return 0
 }
 
-// Illegal insturctions
-// Since these instructions are not used in NES, I'll leave them blank.
+// Unofficial instructions
+// Mostly two official instructions glued together sharing one addressing mode.
+// See http://wiki.nesdev.com/w/index.php/Programming_with_unofficial_opcodes
+
+// SHA, A & X & (H + 1)
 func (cpu *CPU) ahx() uint8 {
+	cpu.storeHigh(cpu.A&cpu.X, cpu.Y)
 	return 0
 }
 
+// AND #imm then LSR A
 func (cpu *CPU) alr() uint8 {
+	cpu.fetch()
+	cpu.A &= cpu.fetched
+	cpu.setFlag(flagCarryBit, cpu.A&0x01 != 0)
+	cpu.A >>= 1
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, false)
 	return 0
 }
 
+// AND #imm, copying N to C
 func (cpu *CPU) anc() uint8 {
+	cpu.fetch()
+	cpu.A &= cpu.fetched
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, cpu.A&0x80 != 0)
+	cpu.setFlag(flagCarryBit, cpu.A&0x80 != 0)
 	return 0
 }
 
+// AND #imm then ROR A, with C and V taken from bit 6 and 5 of the result
 func (cpu *CPU) arr() uint8 {
+	cpu.fetch()
+	cpu.A = ((cpu.A & cpu.fetched) >> 1) | (cpu.getFlag(flagCarryBit) << 7)
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, cpu.A&0x80 != 0)
+	cpu.setFlag(flagCarryBit, cpu.A&0x40 != 0)
+	cpu.setFlag(flagOverflow, ((cpu.A>>6)^(cpu.A>>5))&0x01 != 0)
 	return 0
 }
 
+// X = A & X - #imm, without borrow
 func (cpu *CPU) axs() uint8 {
+	cpu.fetch()
+	value := cpu.A & cpu.X
+	cpu.setFlag(flagCarryBit, value >= cpu.fetched)
+	cpu.X = value - cpu.fetched
+	cpu.setFlag(flagZero, cpu.X == 0x00)
+	cpu.setFlag(flagNegative, cpu.X&0x80 != 0)
 	return 0
 }
 
+// DEC then CMP
 func (cpu *CPU) dcp() uint8 {
+	cpu.fetch()
+	value := cpu.fetched - 1
+	cpu.writeModified(cpu.addrAbs, value)
+	cpu.setFlag(flagCarryBit, cpu.A >= value)
+	cpu.setFlag(flagZero, cpu.A == value)
+	cpu.setFlag(flagNegative, (cpu.A-value)&0x80 != 0)
 	return 0
 }
 
+// INC then SBC
 func (cpu *CPU) isc() uint8 {
+	cpu.fetch()
+	value := cpu.fetched + 1
+	cpu.writeModified(cpu.addrAbs, value)
+	cpu.addWithCarry(value ^ 0xFF)
 	return 0
 }
 
+// Jams the CPU until reset, it keeps reading the same opcode and ignores
+// interrupts.
 func (cpu *CPU) kil() uint8 {
+	cpu.halted = true
+	cpu.PC--
 	return 0
 }
 
+// A, X and SP = memory & SP
 func (cpu *CPU) las() uint8 {
-	return 0
+	cpu.fetch()
+	cpu.SP &= cpu.fetched
+	cpu.A = cpu.SP
+	cpu.X = cpu.SP
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, cpu.A&0x80 != 0)
+	return 1
 }
 
+// LDA and LDX at once
 func (cpu *CPU) lax() uint8 {
-	return 0
+	cpu.fetch()
+	cpu.A = cpu.fetched
+	cpu.X = cpu.fetched
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, cpu.A&0x80 != 0)
+	return 1
 }
 
+// ROL then AND
 func (cpu *CPU) rla() uint8 {
+	cpu.fetch()
+	value := (cpu.fetched << 1) | cpu.getFlag(flagCarryBit)
+	cpu.setFlag(flagCarryBit, cpu.fetched&0x80 != 0)
+	cpu.writeModified(cpu.addrAbs, value)
+	cpu.A &= value
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, cpu.A&0x80 != 0)
 	return 0
 }
 
+// ROR then ADC
 func (cpu *CPU) rra() uint8 {
+	cpu.fetch()
+	value := (cpu.fetched >> 1) | (cpu.getFlag(flagCarryBit) << 7)
+	cpu.setFlag(flagCarryBit, cpu.fetched&0x01 != 0)
+	cpu.writeModified(cpu.addrAbs, value)
+	cpu.addWithCarry(value)
 	return 0
 }
 
+// Store A & X
 func (cpu *CPU) sax() uint8 {
+	cpu.write(cpu.addrAbs, cpu.A&cpu.X)
 	return 0
 }
 
+// X & (H + 1)
 func (cpu *CPU) shx() uint8 {
+	cpu.storeHigh(cpu.X, cpu.Y)
 	return 0
 }
 
+// Y & (H + 1)
 func (cpu *CPU) shy() uint8 {
+	cpu.storeHigh(cpu.Y, cpu.X)
 	return 0
 }
 
+// ASL then ORA
 func (cpu *CPU) slo() uint8 {
+	cpu.fetch()
+	value := cpu.fetched << 1
+	cpu.setFlag(flagCarryBit, cpu.fetched&0x80 != 0)
+	cpu.writeModified(cpu.addrAbs, value)
+	cpu.A |= value
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, cpu.A&0x80 != 0)
 	return 0
 }
 
+// LSR then EOR
 func (cpu *CPU) sre() uint8 {
+	cpu.fetch()
+	value := cpu.fetched >> 1
+	cpu.setFlag(flagCarryBit, cpu.fetched&0x01 != 0)
+	cpu.writeModified(cpu.addrAbs, value)
+	cpu.A ^= value
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, cpu.A&0x80 != 0)
 	return 0
 }
 
+// SP = A & X, then store SP & (H + 1)
 func (cpu *CPU) tas() uint8 {
+	cpu.SP = cpu.A & cpu.X
+	cpu.storeHigh(cpu.SP, cpu.Y)
 	return 0
 }
 
+// ANE, highly unstable on real hardware. Uses the magic constant most
+// consoles show.
 func (cpu *CPU) xaa() uint8 {
+	cpu.fetch()
+	cpu.A = (cpu.A | 0xEE) & cpu.X & cpu.fetched
+	cpu.setFlag(flagZero, cpu.A == 0x00)
+	cpu.setFlag(flagNegative, cpu.A&0x80 != 0)
 	return 0
 }
 
-// This method represents all illegal instructions for convenience.
-func (cpu *CPU) xxx() uint8 {
-	return 0
+// Store value & (high byte of base address + 1) as done by SHA, SHX, SHY and
+// TAS. When indexing crosses a page the stored value also replaces the high
+// byte of the target address.
+func (cpu *CPU) storeHigh(value uint8, index uint8) {
+	base := cpu.addrAbs - uint16(index)
+	data := value & (uint8(base>>8) + 1)
+
+	addr := cpu.addrAbs
+	if (base & 0xFF00) != (addr & 0xFF00) {
+		addr = (uint16(data) << 8) | (addr & 0x00FF)
+	}
+	cpu.write(addr, data)
 }
 
 // Helper functions
@@ -1039,6 +1167,11 @@ func (cpu *CPU) Complete() bool {
 	return cpu.cycles == 0
 }
 
+// Halted Check if CPU is jammed by a KIL instruction, only reset recovers.
+func (cpu *CPU) Halted() bool {
+	return cpu.halted
+}
+
 // Disassemble Converts 6502 binary to human readable 6502 assembly.
 func (cpu *CPU) Disassemble(nStart uint16, nStop uint16) map[uint16]string {
 	var addr uint32 = uint32(nStart)
@@ -0,0 +1,72 @@
+package nes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ROM loading errors, test for them with errors.Is.
+var (
+	ErrBadMagic          = errors.New("not an iNES file")
+	ErrTruncated         = errors.New("file is truncated")
+	ErrBadSize           = errors.New("bad size in header")
+	ErrUnsupportedMapper = errors.New("unsupported mapper")
+	ErrNoROMInArchive    = errors.New("no .nes file in archive")
+	ErrSaveSize          = errors.New("save data larger than PRG RAM")
+	ErrBadPatch          = errors.New("bad patch")
+	ErrPatchChecksum     = errors.New("patch checksum mismatch")
+)
+
+// Save state errors, test for them with errors.Is.
+var (
+	ErrNotState     = errors.New("not a save state")
+	ErrStateVersion = errors.New("unsupported save state version")
+	ErrStateROM     = errors.New("save state belongs to another ROM")
+	ErrStateCorrupt = errors.New("save state is corrupt")
+)
+
+// ROMError Returned by NewCartridge and friends, tells which part of the file
+// failed to load. Err is one of the errors above or an I/O error.
+type ROMError struct {
+	Section string // "file", "patch", "header", "trainer", "PRG ROM", "CHR ROM", "mapper" or "save"
+	Err     error
+}
+
+func (e *ROMError) Error() string {
+	return "failed to load " + e.Section + ": " + e.Err.Error()
+}
+
+// Unwrap Return the underlying error.
+func (e *ROMError) Unwrap() error {
+	return e.Err
+}
+
+// TruncatedError File ended before a section was read in full.
+type TruncatedError struct {
+	Expected int // Bytes the header asked for.
+	Actual   int // Bytes left in file.
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("%s, expected %d bytes but got %d", ErrTruncated, e.Expected, e.Actual)
+}
+
+// Is Match ErrTruncated.
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
+
+// MapperError No mapper is registered for the board, see RegisterMapper.
+type MapperError struct {
+	Mapper    uint16
+	Submapper uint8
+}
+
+func (e *MapperError) Error() string {
+	return fmt.Sprintf("%s %d.%d", ErrUnsupportedMapper, e.Mapper, e.Submapper)
+}
+
+// Is Match ErrUnsupportedMapper.
+func (e *MapperError) Is(target error) bool {
+	return target == ErrUnsupportedMapper
+}
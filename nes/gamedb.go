@@ -0,0 +1,288 @@
+package nes
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GameInfo Game database entry, used to identify dumps and fix their headers.
+type GameInfo struct {
+	Title string
+
+	CRC32   uint32
+	SHA1    [20]byte
+	HasSHA1 bool // Match by CRC32 alone if false.
+
+	Mapper     uint16
+	Submapper  uint8
+	Mirror     int // MirrorHorizontal, MirrorVertical or -1 to trust the header.
+	FourScreen bool
+	Battery    bool
+	Timing     int
+}
+
+var (
+	gameDatabaseLock sync.RWMutex
+	gameDatabase     = map[uint32][]GameInfo{}
+)
+
+// AddGame Add a game to the database, replacing entries with the same hashes.
+func AddGame(game GameInfo) {
+	gameDatabaseLock.Lock()
+	defer gameDatabaseLock.Unlock()
+
+	games := gameDatabase[game.CRC32]
+	for i := range games {
+		if games[i].HasSHA1 == game.HasSHA1 && games[i].SHA1 == game.SHA1 {
+			games[i] = game
+			return
+		}
+	}
+	gameDatabase[game.CRC32] = append(games, game)
+}
+
+// LoadGameDatabase Add games read from a text database or from NES 2.0 XML
+// database (nes20db.xml). There are no games built in, so headers are only
+// fixed for games loaded here.
+//
+// Text database has one game per line:
+// CRC32 SHA-1 mapper[.submapper] mirroring battery region title
+//
+// Hashes are of PRG ROM followed by CHR ROM, without header and trainer. SHA-1
+// can be "-" to match by CRC32 alone. Mirroring is H, V, 4 for four-screen or
+// "-" to keep what header says. Region is NTSC, PAL, Multi or Dendy. Empty
+// lines and lines starting with # are skipped.
+func LoadGameDatabase(r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == 0xEF || c == 0xBB || c == 0xBF {
+			continue // Leading space and UTF-8 BOM
+		}
+		reader.UnreadByte()
+		if c == '<' {
+			return loadNES20Database(reader)
+		}
+		break
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		game, err := parseGame(text)
+		if err != nil {
+			return fmt.Errorf("game database line %d: %w", line, err)
+		}
+		AddGame(game)
+	}
+
+	return scanner.Err()
+}
+
+func parseGame(text string) (GameInfo, error) {
+	game := GameInfo{}
+
+	fields := strings.Fields(text)
+	if len(fields) < 7 {
+		return game, fmt.Errorf("expected 7 fields, got %d", len(fields))
+	}
+
+	crc, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return game, err
+	}
+	game.CRC32 = uint32(crc)
+
+	if fields[1] != "-" {
+		sha1, err := hex.DecodeString(fields[1])
+		if err != nil || len(sha1) != len(game.SHA1) {
+			return game, fmt.Errorf("bad SHA-1 %q", fields[1])
+		}
+		copy(game.SHA1[:], sha1)
+		game.HasSHA1 = true
+	}
+
+	mapper := strings.SplitN(fields[2], ".", 2)
+	id, err := strconv.ParseUint(mapper[0], 10, 12)
+	if err != nil {
+		return game, err
+	}
+	game.Mapper = uint16(id)
+	if len(mapper) > 1 {
+		submapper, err := strconv.ParseUint(mapper[1], 10, 4)
+		if err != nil {
+			return game, err
+		}
+		game.Submapper = uint8(submapper)
+	}
+
+	switch fields[3] {
+	case "H":
+		game.Mirror = mirrorHorizontal
+	case "V":
+		game.Mirror = mirrorVertical
+	case "4":
+		game.Mirror = -1
+		game.FourScreen = true
+	case "-":
+		game.Mirror = -1
+	default:
+		return game, fmt.Errorf("bad mirroring %q", fields[3])
+	}
+
+	game.Battery = fields[4] == "1"
+
+	switch fields[5] {
+	case "NTSC":
+		game.Timing = TimingNTSC
+	case "PAL":
+		game.Timing = TimingPAL
+	case "Multi":
+		game.Timing = TimingMulti
+	case "Dendy":
+		game.Timing = TimingDendy
+	default:
+		return game, fmt.Errorf("bad region %q", fields[5])
+	}
+
+	game.Title = strings.Join(fields[6:], " ")
+
+	return game, nil
+}
+
+// LookupGame Find a game by hashes of its PRG and CHR ROM. Entries with SHA-1
+// win over CRC32 only ones.
+func LookupGame(crc uint32, sha1 [20]byte) (GameInfo, bool) {
+	gameDatabaseLock.RLock()
+	defer gameDatabaseLock.RUnlock()
+
+	var found *GameInfo
+	games := gameDatabase[crc]
+	for i := range games {
+		if games[i].HasSHA1 && games[i].SHA1 == sha1 {
+			return games[i], true
+		}
+		if !games[i].HasSHA1 && found == nil {
+			found = &games[i]
+		}
+	}
+
+	if found != nil {
+		return *found, true
+	}
+	return GameInfo{}, false
+}
+
+// Game of NES 2.0 XML database. Title is the file name in a comment.
+type nes20Game struct {
+	Comment string `xml:",comment"`
+	ROM     struct {
+		CRC32 string `xml:"crc32,attr"`
+		SHA1  string `xml:"sha1,attr"`
+	} `xml:"rom"`
+	PCB struct {
+		Mapper    uint16 `xml:"mapper,attr"`
+		Submapper uint8  `xml:"submapper,attr"`
+		Mirroring string `xml:"mirroring,attr"`
+		Battery   int    `xml:"battery,attr"`
+	} `xml:"pcb"`
+	PRGNVRAM *struct{} `xml:"prgnvram"`
+	Console  struct {
+		Region int `xml:"region,attr"`
+	} `xml:"console"`
+}
+
+func loadNES20Database(r io.Reader) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("game database: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "game" {
+			continue
+		}
+
+		var entry nes20Game
+		if err := decoder.DecodeElement(&entry, &start); err != nil {
+			return fmt.Errorf("game database: %w", err)
+		}
+		game, err := entry.game()
+		if err != nil {
+			line, _ := decoder.InputPos()
+			return fmt.Errorf("game database line %d: %w", line, err)
+		}
+		AddGame(game)
+	}
+}
+
+func (entry *nes20Game) game() (GameInfo, error) {
+	game := GameInfo{
+		Mapper:    entry.PCB.Mapper,
+		Submapper: entry.PCB.Submapper,
+		Mirror:    -1,
+		Battery:   entry.PCB.Battery != 0 || entry.PRGNVRAM != nil,
+	}
+
+	crc, err := strconv.ParseUint(entry.ROM.CRC32, 16, 32)
+	if err != nil {
+		return game, fmt.Errorf("bad CRC32 %q", entry.ROM.CRC32)
+	}
+	game.CRC32 = uint32(crc)
+	if entry.ROM.SHA1 != "" {
+		sha1, err := hex.DecodeString(entry.ROM.SHA1)
+		if err != nil || len(sha1) != len(game.SHA1) {
+			return game, fmt.Errorf("bad SHA-1 %q", entry.ROM.SHA1)
+		}
+		copy(game.SHA1[:], sha1)
+		game.HasSHA1 = true
+	}
+
+	switch entry.PCB.Mirroring {
+	case "H":
+		game.Mirror = mirrorHorizontal
+	case "V":
+		game.Mirror = mirrorVertical
+	case "4":
+		game.FourScreen = true
+	}
+
+	switch entry.Console.Region {
+	case 1:
+		game.Timing = TimingPAL
+	case 2:
+		game.Timing = TimingMulti
+	case 3:
+		game.Timing = TimingDendy
+	default:
+		game.Timing = TimingNTSC
+	}
+
+	if title := strings.TrimSpace(strings.Replace(entry.Comment, "\\", "/", -1)); title != "" {
+		title = path.Base(title)
+		game.Title = strings.TrimSuffix(title, path.Ext(title))
+	}
+
+	return game, nil
+}
@@ -0,0 +1,217 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+)
+
+// Remove test entries from the database when a test is done.
+func removeGames(crcs ...uint32) {
+	gameDatabaseLock.Lock()
+	defer gameDatabaseLock.Unlock()
+	for _, crc := range crcs {
+		delete(gameDatabase, crc)
+	}
+}
+
+func TestParseGame(t *testing.T) {
+	tests := []struct {
+		line string
+		game GameInfo
+		err  bool
+	}{
+		{
+			line: "3337EC46 - 0 V 0 NTSC Super Mario Bros.",
+			game: GameInfo{Title: "Super Mario Bros.", CRC32: 0x3337EC46, Mirror: mirrorVertical, Timing: TimingNTSC},
+		},
+		{
+			line: "0000BEEF 4131307F0F69F2A5C54B7D438328C5B2A5ED0820 4.1 4 1 PAL Some  Game",
+			game: GameInfo{
+				Title: "Some Game", CRC32: 0xBEEF,
+				SHA1:    [20]byte{0x41, 0x31, 0x30, 0x7F, 0x0F, 0x69, 0xF2, 0xA5, 0xC5, 0x4B, 0x7D, 0x43, 0x83, 0x28, 0xC5, 0xB2, 0xA5, 0xED, 0x08, 0x20},
+				HasSHA1: true, Mapper: 4, Submapper: 1, Mirror: -1, FourScreen: true, Battery: true, Timing: TimingPAL,
+			},
+		},
+		{
+			line: "12345678 - 4095 H 0 Dendy Clone",
+			game: GameInfo{Title: "Clone", CRC32: 0x12345678, Mapper: 4095, Mirror: mirrorHorizontal, Timing: TimingDendy},
+		},
+		{line: "12345678 - 0 V 0 NTSC", err: true},              // No title
+		{line: "XYZ - 0 V 0 NTSC Bad CRC", err: true},           // CRC32 not hex
+		{line: "12345678 ABCD 0 V 0 NTSC Short", err: true},     // SHA-1 too short
+		{line: "12345678 - 4096 V 0 NTSC Mapper", err: true},    // Mapper over 12 bits
+		{line: "12345678 - 1.16 V 0 NTSC Submapper", err: true}, // Submapper over 4 bits
+		{line: "12345678 - 0 X 0 NTSC Mirroring", err: true},
+		{line: "12345678 - 0 V 0 SECAM Region", err: true},
+	}
+
+	for _, test := range tests {
+		game, err := parseGame(test.line)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: parsed without error", test.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.line, err)
+		} else if game != test.game {
+			t.Errorf("%q: got %+v, expected %+v", test.line, game, test.game)
+		}
+	}
+}
+
+// TestLookupGame Entry with matching SHA-1 wins over CRC32 only one, whatever
+// the order they were added in. Entries with another SHA-1 never match.
+func TestLookupGame(t *testing.T) {
+	const crc = 0xC0FFEE00
+	defer removeGames(crc)
+	sha1 := [20]byte{1, 2, 3}
+	other := [20]byte{4, 5, 6}
+
+	AddGame(GameInfo{Title: "CRC only", CRC32: crc})
+	AddGame(GameInfo{Title: "Other", CRC32: crc, SHA1: other, HasSHA1: true})
+	AddGame(GameInfo{Title: "Exact", CRC32: crc, SHA1: sha1, HasSHA1: true})
+
+	tests := []struct {
+		crc   uint32
+		sha1  [20]byte
+		title string
+		found bool
+	}{
+		{crc, sha1, "Exact", true},
+		{crc, other, "Other", true},
+		{crc, [20]byte{7}, "CRC only", true},
+		{crc + 1, sha1, "", false},
+	}
+	for _, test := range tests {
+		game, found := LookupGame(test.crc, test.sha1)
+		if found != test.found || game.Title != test.title {
+			t.Errorf("%08X %x: got %q %t, expected %q %t", test.crc, test.sha1, game.Title, found, test.title, test.found)
+		}
+	}
+
+	// Adding the same hashes again replaces the entry.
+	AddGame(GameInfo{Title: "Replaced", CRC32: crc})
+	if game, _ := LookupGame(crc, [20]byte{}); game.Title != "Replaced" {
+		t.Errorf("got %q, expected replaced entry", game.Title)
+	}
+}
+
+func TestGameInfoCorrect(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     CartridgeInfo
+		game     GameInfo
+		expected CartridgeInfo
+	}{
+		{
+			name:     "battery added",
+			info:     CartridgeInfo{Mapper: 1, PRGRAMSize: 8192, Mirror: mirrorVertical},
+			game:     GameInfo{Mapper: 1, Mirror: -1, Battery: true, Timing: TimingNTSC},
+			expected: CartridgeInfo{Mapper: 1, PRGNVRAMSize: 8192, Mirror: mirrorVertical, Battery: true, Corrected: true},
+		},
+		{
+			name:     "battery removed",
+			info:     CartridgeInfo{Mapper: 4, PRGNVRAMSize: 8192, Battery: true},
+			game:     GameInfo{Mapper: 4, Submapper: 1, Mirror: mirrorVertical, Timing: TimingPAL},
+			expected: CartridgeInfo{Mapper: 4, Submapper: 1, PRGRAMSize: 8192, Mirror: mirrorVertical, Timing: TimingPAL, Corrected: true},
+		},
+		{
+			name:     "four-screen",
+			info:     CartridgeInfo{Mapper: 0, Mirror: mirrorHorizontal},
+			game:     GameInfo{Mapper: 206, Mirror: -1, FourScreen: true},
+			expected: CartridgeInfo{Mapper: 206, Mirror: mirrorHorizontal, FourScreen: true, Corrected: true},
+		},
+		{
+			name:     "four-screen of header kept",
+			info:     CartridgeInfo{Mapper: 4, Mirror: mirrorVertical, FourScreen: true},
+			game:     GameInfo{Mapper: 4, Mirror: -1},
+			expected: CartridgeInfo{Mapper: 4, Mirror: mirrorVertical, FourScreen: true, Corrected: true},
+		},
+		{
+			name:     "four-screen removed",
+			info:     CartridgeInfo{Mapper: 4, Mirror: mirrorVertical, FourScreen: true},
+			game:     GameInfo{Mapper: 4, Mirror: mirrorHorizontal},
+			expected: CartridgeInfo{Mapper: 4, Mirror: mirrorHorizontal, Corrected: true},
+		},
+	}
+	for _, test := range tests {
+		info := test.info
+		info.correct(&test.game)
+		if info != test.expected {
+			t.Errorf("%s: got %+v, expected %+v", test.name, info, test.expected)
+		}
+	}
+}
+
+// TestGameDatabaseBattery Header claiming a battery is corrected by the
+// database, so the game doesn't write a save file.
+func TestGameDatabaseBattery(t *testing.T) {
+	rom := makeROM(0, 1, 1, 0x02, []byte{0x01})
+	cart, err := NewCartridgeFromBytes(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cart.battery {
+		t.Fatalf("battery flag of header ignored")
+	}
+
+	hash := cart.Hash()
+	defer removeGames(hash.CRC32)
+	AddGame(GameInfo{Title: "No battery", CRC32: hash.CRC32, SHA1: hash.SHA1, HasSHA1: true, Mirror: -1})
+
+	cart, err = NewCartridgeFromBytes(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := cart.Info()
+	if cart.battery || info.Battery || info.PRGNVRAMSize != 0 || info.PRGRAMSize != 8192 {
+		t.Errorf("got battery %t, %+v, expected 8KB of PRG RAM without battery", cart.battery, info)
+	}
+}
+
+func TestLoadNES20Database(t *testing.T) {
+	const database = `<?xml version="1.0" encoding="UTF-8"?>
+<nes20db date="2024-01-01">
+	<game>
+		<!-- \Licensed\Test Game (USA).nes -->
+		<prgrom size="131072" crc32="11111111" sha1="0000000000000000000000000000000000000000" sum16="0000"/>
+		<chrrom size="131072" crc32="22222222" sha1="0000000000000000000000000000000000000000" sum16="0000"/>
+		<rom size="262144" crc32="0BADF00D" sha1="4131307F0F69F2A5C54B7D438328C5B2A5ED0820"/>
+		<prgnvram size="8192"/>
+		<pcb mapper="4" submapper="0" mirroring="V" battery="1"/>
+		<console type="0" region="1"/>
+	</game>
+	<game>
+		<!-- \Unlicensed\Other.nes -->
+		<rom size="40960" crc32="0BADF00E" sha1="4131307F0F69F2A5C54B7D438328C5B2A5ED0821"/>
+		<pcb mapper="206" submapper="1" mirroring="4" battery="0"/>
+		<console type="0" region="0"/>
+	</game>
+</nes20db>`
+	defer removeGames(0x0BADF00D, 0x0BADF00E)
+
+	if err := LoadGameDatabase(strings.NewReader(database)); err != nil {
+		t.Fatal(err)
+	}
+
+	game, found := LookupGame(0x0BADF00D, [20]byte{0x41, 0x31, 0x30, 0x7F, 0x0F, 0x69, 0xF2, 0xA5, 0xC5, 0x4B, 0x7D, 0x43, 0x83, 0x28, 0xC5, 0xB2, 0xA5, 0xED, 0x08, 0x20})
+	if !found || game.Title != "Test Game (USA)" || game.Mapper != 4 || game.Mirror != mirrorVertical || !game.Battery || game.Timing != TimingPAL {
+		t.Errorf("got %+v %t", game, found)
+	}
+	game, found = LookupGame(0x0BADF00E, [20]byte{})
+	if found {
+		t.Errorf("matched %q with wrong SHA-1", game.Title)
+	}
+	gameDatabaseLock.RLock()
+	game = gameDatabase[0x0BADF00E][0]
+	gameDatabaseLock.RUnlock()
+	if game.Title != "Other" || game.Mapper != 206 || game.Submapper != 1 || !game.FourScreen || game.Mirror != -1 || game.Battery {
+		t.Errorf("got %+v", game)
+	}
+
+	if err := LoadGameDatabase(strings.NewReader(`<nes20db><game><rom crc32="nope"/></game></nes20db>`)); err == nil {
+		t.Errorf("bad CRC32 loaded without error")
+	}
+}
@@ -0,0 +1,135 @@
+package nes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Controller buttons, as bits of Bus.Controller.
+const (
+	ButtonA      = (1 << 7)
+	ButtonB      = (1 << 6)
+	ButtonSelect = (1 << 5)
+	ButtonStart  = (1 << 4)
+	ButtonUp     = (1 << 3)
+	ButtonDown   = (1 << 2)
+	ButtonLeft   = (1 << 1)
+	ButtonRight  = (1 << 0)
+)
+
+var buttonNames = []struct {
+	name string
+	bit  uint8
+}{
+	{"A", ButtonA},
+	{"B", ButtonB},
+	{"SELECT", ButtonSelect},
+	{"START", ButtonStart},
+	{"UP", ButtonUp},
+	{"DOWN", ButtonDown},
+	{"LEFT", ButtonLeft},
+	{"RIGHT", ButtonRight},
+}
+
+// ParseButtons Parse buttons joined by "+", e.g. "A+START". "-" means none.
+func ParseButtons(s string) (uint8, error) {
+	var buttons uint8 = 0x00
+	if s == "-" {
+		return buttons, nil
+	}
+
+	for _, name := range strings.Split(strings.ToUpper(s), "+") {
+		found := false
+		for _, button := range buttonNames {
+			if button.name == name {
+				buttons |= button.bit
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown button %q", name)
+		}
+	}
+	return buttons, nil
+}
+
+// FormatButtons Format buttons the way ParseButtons reads them.
+func FormatButtons(buttons uint8) string {
+	var names []string
+	for _, button := range buttonNames {
+		if buttons&button.bit != 0 {
+			names = append(names, button.name)
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, "+")
+}
+
+type inputEvent struct {
+	frame int
+	pads  [2]uint8
+}
+
+// InputScript Scripted controller input. Each line of a script reads
+// "frame pad1 [pad2]", and the buttons are held from that frame until the
+// next line. Lines starting with "#" are comments.
+type InputScript struct {
+	events []inputEvent
+}
+
+// LoadInputScript Read an input script.
+func LoadInputScript(r io.Reader) (*InputScript, error) {
+	script := InputScript{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("input script line %d: want \"frame pad1 [pad2]\"", line)
+		}
+
+		event := inputEvent{}
+		frame, err := strconv.Atoi(fields[0])
+		if err != nil || frame < 0 {
+			return nil, fmt.Errorf("input script line %d: bad frame %q", line, fields[0])
+		}
+		if n := len(script.events); n > 0 && frame <= script.events[n-1].frame {
+			return nil, fmt.Errorf("input script line %d: frame %d is not after frame %d", line, frame, script.events[n-1].frame)
+		}
+		event.frame = frame
+
+		for i, pad := range fields[1:] {
+			if event.pads[i], err = ParseButtons(pad); err != nil {
+				return nil, fmt.Errorf("input script line %d: %w", line, err)
+			}
+		}
+		script.events = append(script.events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &script, nil
+}
+
+// Apply Set controllers to the state the script holds at frame.
+func (script *InputScript) Apply(bus *Bus, frame int) {
+	i := sort.Search(len(script.events), func(i int) bool {
+		return script.events[i].frame > frame
+	})
+
+	var pads [2]uint8
+	if i > 0 {
+		pads = script.events[i-1].pads
+	}
+	bus.Controller[0] = pads[0]
+	bus.Controller[1] = pads[1]
+}
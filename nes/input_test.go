@@ -0,0 +1,105 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseButtons(t *testing.T) {
+	tests := []struct {
+		text    string
+		buttons uint8
+		err     bool
+	}{
+		{"-", 0, false},
+		{"A", ButtonA, false},
+		{"a+start", ButtonA | ButtonStart, false},
+		{"UP+DOWN+LEFT+RIGHT+B+SELECT", ButtonUp | ButtonDown | ButtonLeft | ButtonRight | ButtonB | ButtonSelect, false},
+		{"", 0, true},
+		{"A+", 0, true},
+		{"TURBO", 0, true},
+	}
+
+	for _, test := range tests {
+		buttons, err := ParseButtons(test.text)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: parsed without error", test.text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+		} else if buttons != test.buttons {
+			t.Errorf("%q: got %08b, expected %08b", test.text, buttons, test.buttons)
+		}
+		if again, _ := ParseButtons(FormatButtons(buttons)); again != buttons {
+			t.Errorf("%q: formatted as %q, which reads back as %08b", test.text, FormatButtons(buttons), again)
+		}
+	}
+}
+
+// TestInputScript Buttons of each line are held until the next one, for one
+// or both pads.
+func TestInputScript(t *testing.T) {
+	script, err := LoadInputScript(strings.NewReader(`
+# Press start, then walk right with jumps on pad 2.
+10 START
+12 -
+60 RIGHT A+B
+90 right+a
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := NewBus()
+	tests := []struct {
+		frame int
+		pads  [2]uint8
+	}{
+		{0, [2]uint8{0, 0}},
+		{9, [2]uint8{0, 0}},
+		{10, [2]uint8{ButtonStart, 0}},
+		{11, [2]uint8{ButtonStart, 0}},
+		{12, [2]uint8{0, 0}},
+		{60, [2]uint8{ButtonRight, ButtonA | ButtonB}},
+		{89, [2]uint8{ButtonRight, ButtonA | ButtonB}},
+		{90, [2]uint8{ButtonRight | ButtonA, 0}},
+		{1000, [2]uint8{ButtonRight | ButtonA, 0}},
+		{5, [2]uint8{0, 0}}, // Frames can be applied in any order.
+	}
+	for _, test := range tests {
+		bus.Controller[0], bus.Controller[1] = 0xFF, 0xFF
+		script.Apply(bus, test.frame)
+		if pads := [2]uint8{bus.Controller[0], bus.Controller[1]}; pads != test.pads {
+			t.Errorf("frame %d: got %08b, expected %08b", test.frame, pads, test.pads)
+		}
+	}
+}
+
+func TestInputScriptErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"descending frames", "10 A\n5 B\n", "line 2: frame 5 is not after frame 10"},
+		{"same frame", "10 A\n10 B\n", "line 2: frame 10 is not after frame 10"},
+		{"bad frame", "ten A\n", "line 1: bad frame"},
+		{"negative frame", "-1 A\n", "line 1: bad frame"},
+		{"bad button", "# comment\n\n3 A+JUMP\n", "line 3: unknown button \"JUMP\""},
+		{"bad button of pad 2", "0 A START+X\n", "line 1: unknown button \"X\""},
+		{"no pads", "10\n", "line 1: want"},
+		{"three pads", "10 A B START\n", "line 1: want"},
+	}
+
+	for _, test := range tests {
+		script, err := LoadInputScript(strings.NewReader(test.script))
+		if script != nil || err == nil {
+			t.Errorf("%s: loaded without error", test.name)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %q, expected %q", test.name, err, test.err)
+		}
+	}
+}
@@ -1,9 +1,151 @@
 package nes
 
+import "sync"
+
+// Returned through mappedAddr when a mapper consumed a CPU write to its own
+// registers, so there's nothing to write into cartridge memory.
+const mappedRegister uint32 = 0xFFFFFFFF
+
+// Exported names for mappers implemented outside this package.
+const (
+	MirrorHorizontal  = mirrorHorizontal
+	MirrorVertical    = mirrorVertical
+	MirrorOneScreenLo = oneScreenLo
+	MirrorOneScreenHi = oneScrennHi
+	MirrorFourScreen  = mirrorFourScreen
+	MirrorHardware    = mirrorHardware
+
+	MappedRegister = mappedRegister
+)
+
 // Mapper Generic mapper interface.
+//
+// CPUMapRead and CPUMapWrite translate $6000-$7FFF into an offset of cartridge
+// PRG RAM and $8000-$FFFF into an offset of PRG ROM. PPU side translates
+// $0000-$1FFF into an offset of CHR memory.
 type Mapper interface {
 	CPUMapRead(addr uint16, mappedAddr *uint32) bool
-	CPUMapWrite(addr uint16, mappedAddr *uint32) bool
+	CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool
 	PPUMapRead(addr uint16, mappedAddr *uint32) bool
 	PPUMapWrite(addr uint16, mappedAddr *uint32) bool
+
+	// Mirror returns current name table mirroring, or mirrorHardware if the
+	// mirroring is soldered on board and given by the file header.
+	Mirror() int
+	Reset()
+
+	// StateFields returns pointers to the registers kept in save states, in
+	// the order WriteState writes them. Bank counts and cartridge memory are
+	// handled by the cartridge.
+	StateFields() []interface{}
+}
+
+// IRQSource Implemented by mappers able to pull the CPU IRQ line, bus polls
+// it every CPU cycle.
+type IRQSource interface {
+	IRQState() bool
+}
+
+// PPUAddressWatcher Implemented by mappers snooping PPU address bus, PPU calls
+// it with every pattern fetch made while rendering and every $2006/$2007 access.
+type PPUAddressWatcher interface {
+	PPUAddress(addr uint16)
+}
+
+// CPUCycleWatcher Implemented by mappers that need to count CPU cycles (M2),
+// bus calls it once every CPU cycle before the CPU runs.
+type CPUCycleWatcher interface {
+	CPUCycle()
+}
+
+// Name table sources, see NameTableMapper.
+const (
+	NameTableCIRAM        = iota // Console VRAM, page 0 or 1.
+	NameTableCartridgeRAM        // Cartridge VRAM, 1KB pages.
+	NameTableCHR                 // CHR ROM or RAM, 1KB pages.
+)
+
+// NameTableMapper Implemented by mappers wiring each name table themselves,
+// overriding Mirror. PPU asks on every name table access, so mapping can change
+// mid-frame.
+type NameTableMapper interface {
+	// MapNameTable returns source and 1KB page backing name table 0-3.
+	MapNameTable(table uint16) (source int, page uint32)
+}
+
+// BusConflicter Implemented by discrete logic boards whose PRG ROM keeps
+// driving the data bus while the CPU writes a register, so the value latched
+// is the written value ANDed with the ROM byte at that address.
+type BusConflicter interface {
+	BusConflicts() bool
+}
+
+// Number of 32KB PRG banks made of the given number of 16KB banks.
+func prgBanks32(prgBanks uint8) uint8 {
+	if prgBanks < 2 {
+		return 1
+	}
+	return prgBanks / 2
+}
+
+// MapperFactory Creates a mapper for a board with the given number of 16KB PRG
+// banks and 8KB CHR banks. A CHR bank count of 0 means the board has CHR RAM.
+// info carries the submapper, RAM sizes and everything else the header says.
+type MapperFactory func(info CartridgeInfo, prgBanks uint8, chrBanks uint8) Mapper
+
+// Factory of a built-in mapper, which only needs the bank counts.
+func banksOnly(newMapper func(prgBanks uint8, chrBanks uint8) Mapper) MapperFactory {
+	return func(info CartridgeInfo, prgBanks uint8, chrBanks uint8) Mapper {
+		return newMapper(prgBanks, chrBanks)
+	}
+}
+
+type mapperKey struct {
+	id        uint16
+	submapper uint8
+}
+
+var (
+	mapperRegistryLock sync.RWMutex
+	mapperRegistry     = map[mapperKey]MapperFactory{}
+)
+
+func init() {
+	RegisterMapper(0, 0, banksOnly(NewMapper0))
+	RegisterMapper(1, 0, banksOnly(NewMapper1))
+	RegisterMapper(2, 0, banksOnly(NewMapper2))
+	RegisterMapper(3, 0, banksOnly(NewMapper3))
+	RegisterMapper(4, 0, banksOnly(NewMapper4))
+	RegisterMapper(7, 0, banksOnly(NewMapper7))
+	RegisterMapper(11, 0, banksOnly(NewMapper11))
+	RegisterMapper(34, 0, banksOnly(NewMapper34))
+	RegisterMapper(34, 1, mapper34Board(true))
+	RegisterMapper(34, 2, mapper34Board(false))
+	RegisterMapper(66, 0, banksOnly(NewMapper66))
+}
+
+// RegisterMapper Register factory of an iNES mapper number and NES 2.0
+// submapper, replacing any previous one. Submapper 0 doubles as the fallback
+// for submappers without their own factory. Usually called from init().
+func RegisterMapper(id uint16, submapper uint8, factory MapperFactory) {
+	mapperRegistryLock.Lock()
+	defer mapperRegistryLock.Unlock()
+
+	if factory == nil {
+		delete(mapperRegistry, mapperKey{id, submapper})
+		return
+	}
+	mapperRegistry[mapperKey{id, submapper}] = factory
+}
+
+// Find factory of the given mapper and submapper.
+func lookupMapper(id uint16, submapper uint8) (MapperFactory, bool) {
+	mapperRegistryLock.RLock()
+	defer mapperRegistryLock.RUnlock()
+
+	if factory, ok := mapperRegistry[mapperKey{id, submapper}]; ok {
+		return factory, true
+	}
+	factory, ok := mapperRegistry[mapperKey{id, 0}]
+	return factory, ok
 }
@@ -16,7 +16,11 @@ func NewMapper0(prgBanks uint8, chrBanks uint8) Mapper {
 
 // CPUMapRead Mapper0's CPUMapRead implementation.
 func (mapper *Mapper0) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
-	if addr >= 0x8000 && addr <= 0xFFFF {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		// Family BASIC has PRG RAM.
+		*mappedAddr = uint32(addr & 0x1FFF)
+		return true
+	} else if addr >= 0x8000 && addr <= 0xFFFF {
 		if mapper.prgBanks > 1 {
 			*mappedAddr = uint32(addr & 0x7FFF)
 		} else {
@@ -29,8 +33,11 @@ func (mapper *Mapper0) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
 }
 
 // CPUMapWrite Mapper0's CPUMapWrite implementation.
-func (mapper *Mapper0) CPUMapWrite(addr uint16, mappedAddr *uint32) bool {
-	if addr >= 0x8000 && addr <= 0xFFFF {
+func (mapper *Mapper0) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		*mappedAddr = uint32(addr & 0x1FFF)
+		return true
+	} else if addr >= 0x8000 && addr <= 0xFFFF {
 		if mapper.prgBanks > 1 {
 			*mappedAddr = uint32(addr & 0x7FFF)
 		} else {
@@ -64,3 +71,18 @@ func (mapper *Mapper0) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
 
 	return false
 }
+
+// Mirror Mapper0's Mirror implementation.
+func (mapper *Mapper0) Mirror() int {
+	return mirrorHardware
+}
+
+// Reset Mapper0's Reset implementation.
+func (mapper *Mapper0) Reset() {
+
+}
+
+// StateFields Mapper0 has no registers.
+func (mapper *Mapper0) StateFields() []interface{} {
+	return nil
+}
@@ -0,0 +1,204 @@
+package nes
+
+// Mapper1 MMC1 (SxROM) struct
+// See http://wiki.nesdev.com/w/index.php/MMC1
+type Mapper1 struct {
+	prgBanks uint8
+	chrBanks uint8
+
+	// Serial port, registers are written one bit at a time.
+	loadRegister uint8
+	loadCount    uint8
+	writeCycles  uint8 // CPU cycles since last serial write, saturating.
+
+	controlRegister uint8 // CPPMM, CHR mode, PRG mode, mirroring
+	chrBank0        uint8
+	chrBank1        uint8
+	prgBank         uint8 // RPPPP, PRG RAM disable and PRG bank
+}
+
+// NewMapper1 Creates a new mapper of mapper1.
+func NewMapper1(prgBanks uint8, chrBanks uint8) Mapper {
+	mapper := Mapper1{}
+	mapper.prgBanks = prgBanks
+	mapper.chrBanks = chrBanks
+	mapper.Reset()
+	return &mapper
+}
+
+// Get 16KB PRG bank number mapped to the given CPU address.
+func (mapper *Mapper1) prgBank16(addr uint16) uint32 {
+	var bank uint8
+	switch (mapper.controlRegister >> 2) & 0x03 {
+	case 0, 1:
+		// Switch 32KB at $8000, ignoring low bit of bank number.
+		bank = (mapper.prgBank & 0x0E) | uint8((addr>>14)&0x01)
+	case 2:
+		// Fix first bank at $8000 and switch 16KB bank at $C000.
+		if addr < 0xC000 {
+			bank = 0
+		} else {
+			bank = mapper.prgBank & 0x0F
+		}
+	case 3:
+		// Fix last bank at $C000 and switch 16KB bank at $8000.
+		if addr < 0xC000 {
+			bank = mapper.prgBank & 0x0F
+		} else {
+			bank = mapper.prgBanks - 1
+		}
+	}
+
+	return uint32(bank % mapper.prgBanks)
+}
+
+// Get 4KB CHR bank number mapped to the given PPU address.
+func (mapper *Mapper1) chrBank4(addr uint16) uint32 {
+	var bank uint8
+	if mapper.controlRegister&0x10 != 0 {
+		// Switch two separate 4KB banks.
+		if addr < 0x1000 {
+			bank = mapper.chrBank0
+		} else {
+			bank = mapper.chrBank1
+		}
+	} else {
+		// Switch 8KB at a time, ignoring low bit of bank number.
+		bank = (mapper.chrBank0 & 0x1E) | uint8((addr>>12)&0x01)
+	}
+
+	return uint32(bank) % (uint32(mapper.chrBanks) * 2)
+}
+
+// CPUMapRead Mapper1's CPUMapRead implementation.
+func (mapper *Mapper1) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		if mapper.prgBank&0x10 == 0 {
+			*mappedAddr = uint32(addr & 0x1FFF)
+			return true
+		}
+	} else if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mapper.prgBank16(addr)*0x4000 + uint32(addr&0x3FFF)
+		return true
+	}
+
+	return false
+}
+
+// CPUMapWrite Mapper1's CPUMapWrite implementation.
+func (mapper *Mapper1) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		if mapper.prgBank&0x10 == 0 {
+			*mappedAddr = uint32(addr & 0x1FFF)
+			return true
+		}
+	} else if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mappedRegister
+
+		// Serial port ignores a write on the cycle right after another, read-
+		// modify-write instructions rely on it to reset the shift register.
+		consecutive := mapper.writeCycles < 2
+		mapper.writeCycles = 0
+		if consecutive {
+			return true
+		}
+
+		if data&0x80 != 0 {
+			// Reset shift register and lock PRG mode to 3.
+			mapper.loadRegister = 0x00
+			mapper.loadCount = 0
+			mapper.controlRegister |= 0x0C
+			return true
+		}
+
+		mapper.loadRegister >>= 1
+		mapper.loadRegister |= (data & 0x01) << 4
+		mapper.loadCount++
+
+		if mapper.loadCount == 5 {
+			// Fifth write decides the target register by address bit 13 and 14.
+			switch (addr >> 13) & 0x03 {
+			case 0: // $8000-$9FFF
+				mapper.controlRegister = mapper.loadRegister & 0x1F
+			case 1: // $A000-$BFFF
+				mapper.chrBank0 = mapper.loadRegister & 0x1F
+			case 2: // $C000-$DFFF
+				mapper.chrBank1 = mapper.loadRegister & 0x1F
+			case 3: // $E000-$FFFF
+				mapper.prgBank = mapper.loadRegister & 0x1F
+			}
+
+			mapper.loadRegister = 0x00
+			mapper.loadCount = 0
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// PPUMapRead Mapper1's PPUMapRead implementation.
+func (mapper *Mapper1) PPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			*mappedAddr = uint32(addr)
+		} else {
+			*mappedAddr = mapper.chrBank4(addr)*0x1000 + uint32(addr&0x0FFF)
+		}
+		return true
+	}
+
+	return false
+}
+
+// PPUMapWrite Mapper1's PPUMapWrite implementation.
+func (mapper *Mapper1) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			// CHR RAM
+			*mappedAddr = uint32(addr)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mirror Mapper1's Mirror implementation.
+func (mapper *Mapper1) Mirror() int {
+	switch mapper.controlRegister & 0x03 {
+	case 0:
+		return oneScreenLo
+	case 1:
+		return oneScrennHi
+	case 2:
+		return mirrorVertical
+	default:
+		return mirrorHorizontal
+	}
+}
+
+// Reset Mapper1's Reset implementation.
+func (mapper *Mapper1) Reset() {
+	mapper.loadRegister = 0x00
+	mapper.loadCount = 0
+	mapper.writeCycles = 0xFF
+	mapper.controlRegister = 0x1C
+	mapper.chrBank0 = 0
+	mapper.chrBank1 = 0
+	mapper.prgBank = 0
+}
+
+// CPUCycle Mapper1's CPUCycle implementation, times serial port writes.
+func (mapper *Mapper1) CPUCycle() {
+	if mapper.writeCycles < 0xFF {
+		mapper.writeCycles++
+	}
+}
+
+// StateFields Serial shift register, control and bank registers, and CPU
+// cycles since the last write.
+func (mapper *Mapper1) StateFields() []interface{} {
+	return []interface{}{&mapper.loadRegister, &mapper.loadCount, &mapper.writeCycles, &mapper.controlRegister, &mapper.chrBank0, &mapper.chrBank1, &mapper.prgBank}
+}
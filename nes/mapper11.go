@@ -0,0 +1,90 @@
+package nes
+
+// Mapper11 Color Dreams struct
+// See http://wiki.nesdev.com/w/index.php/Color_Dreams
+type Mapper11 struct {
+	prgBanks uint8
+	chrBanks uint8
+
+	prgBankSelect uint8
+	chrBankSelect uint8
+}
+
+// NewMapper11 Creates a new mapper of mapper11.
+func NewMapper11(prgBanks uint8, chrBanks uint8) Mapper {
+	mapper := Mapper11{}
+	mapper.prgBanks = prgBanks
+	mapper.chrBanks = chrBanks
+	mapper.Reset()
+	return &mapper
+}
+
+// CPUMapRead Mapper11's CPUMapRead implementation.
+func (mapper *Mapper11) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = uint32(mapper.prgBankSelect)*0x8000 + uint32(addr&0x7FFF)
+		return true
+	}
+
+	return false
+}
+
+// CPUMapWrite Mapper11's CPUMapWrite implementation.
+func (mapper *Mapper11) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mappedRegister
+
+		// CCCC LLPP, 8KB CHR bank, lockout defeat and 32KB PRG bank.
+		mapper.prgBankSelect = (data & 0x03) % prgBanks32(mapper.prgBanks)
+		if mapper.chrBanks > 0 {
+			mapper.chrBankSelect = (data >> 4) % mapper.chrBanks
+		}
+		return true
+	}
+
+	return false
+}
+
+// PPUMapRead Mapper11's PPUMapRead implementation.
+func (mapper *Mapper11) PPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		*mappedAddr = uint32(mapper.chrBankSelect)*0x2000 + uint32(addr)
+		return true
+	}
+
+	return false
+}
+
+// PPUMapWrite Mapper11's PPUMapWrite implementation.
+func (mapper *Mapper11) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			// CHR RAM
+			*mappedAddr = uint32(addr)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mirror Mapper11's Mirror implementation.
+func (mapper *Mapper11) Mirror() int {
+	return mirrorHardware
+}
+
+// Reset Mapper11's Reset implementation.
+func (mapper *Mapper11) Reset() {
+	mapper.prgBankSelect = 0
+	mapper.chrBankSelect = 0
+}
+
+// BusConflicts Mapper11's BusConflicts implementation.
+func (mapper *Mapper11) BusConflicts() bool {
+	return true
+}
+
+// StateFields Selected PRG and CHR banks.
+func (mapper *Mapper11) StateFields() []interface{} {
+	return []interface{}{&mapper.prgBankSelect, &mapper.chrBankSelect}
+}
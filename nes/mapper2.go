@@ -0,0 +1,88 @@
+package nes
+
+// Mapper2 UxROM struct
+// See http://wiki.nesdev.com/w/index.php/UxROM
+type Mapper2 struct {
+	prgBanks uint8
+	chrBanks uint8
+
+	prgBankSelectLo uint8
+	prgBankSelectHi uint8
+}
+
+// NewMapper2 Creates a new mapper of mapper2.
+func NewMapper2(prgBanks uint8, chrBanks uint8) Mapper {
+	mapper := Mapper2{}
+	mapper.prgBanks = prgBanks
+	mapper.chrBanks = chrBanks
+	mapper.Reset()
+	return &mapper
+}
+
+// CPUMapRead Mapper2's CPUMapRead implementation.
+func (mapper *Mapper2) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x8000 && addr <= 0xBFFF {
+		*mappedAddr = uint32(mapper.prgBankSelectLo)*0x4000 + uint32(addr&0x3FFF)
+		return true
+	} else if addr >= 0xC000 && addr <= 0xFFFF {
+		*mappedAddr = uint32(mapper.prgBankSelectHi)*0x4000 + uint32(addr&0x3FFF)
+		return true
+	}
+
+	return false
+}
+
+// CPUMapWrite Mapper2's CPUMapWrite implementation.
+func (mapper *Mapper2) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mappedRegister
+		mapper.prgBankSelectLo = data % mapper.prgBanks
+		return true
+	}
+
+	return false
+}
+
+// PPUMapRead Mapper2's PPUMapRead implementation.
+func (mapper *Mapper2) PPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		*mappedAddr = uint32(addr)
+		return true
+	}
+
+	return false
+}
+
+// PPUMapWrite Mapper2's PPUMapWrite implementation.
+func (mapper *Mapper2) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			// CHR RAM
+			*mappedAddr = uint32(addr)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mirror Mapper2's Mirror implementation.
+func (mapper *Mapper2) Mirror() int {
+	return mirrorHardware
+}
+
+// Reset Mapper2's Reset implementation.
+func (mapper *Mapper2) Reset() {
+	mapper.prgBankSelectLo = 0
+	mapper.prgBankSelectHi = mapper.prgBanks - 1
+}
+
+// BusConflicts Mapper2's BusConflicts implementation.
+func (mapper *Mapper2) BusConflicts() bool {
+	return true
+}
+
+// StateFields Switchable and fixed 16KB PRG banks.
+func (mapper *Mapper2) StateFields() []interface{} {
+	return []interface{}{&mapper.prgBankSelectLo, &mapper.prgBankSelectHi}
+}
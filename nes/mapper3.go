@@ -0,0 +1,90 @@
+package nes
+
+// Mapper3 CNROM struct
+// See http://wiki.nesdev.com/w/index.php/INES_Mapper_003
+type Mapper3 struct {
+	prgBanks uint8
+	chrBanks uint8
+
+	chrBankSelect uint8
+}
+
+// NewMapper3 Creates a new mapper of mapper3.
+func NewMapper3(prgBanks uint8, chrBanks uint8) Mapper {
+	mapper := Mapper3{}
+	mapper.prgBanks = prgBanks
+	mapper.chrBanks = chrBanks
+	mapper.Reset()
+	return &mapper
+}
+
+// CPUMapRead Mapper3's CPUMapRead implementation.
+func (mapper *Mapper3) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		// Same as mapper0, 16KB boards are mirrored.
+		if mapper.prgBanks > 1 {
+			*mappedAddr = uint32(addr & 0x7FFF)
+		} else {
+			*mappedAddr = uint32(addr & 0x3FFF)
+		}
+		return true
+	}
+
+	return false
+}
+
+// CPUMapWrite Mapper3's CPUMapWrite implementation.
+func (mapper *Mapper3) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mappedRegister
+		if mapper.chrBanks > 0 {
+			mapper.chrBankSelect = data % mapper.chrBanks
+		}
+		return true
+	}
+
+	return false
+}
+
+// PPUMapRead Mapper3's PPUMapRead implementation.
+func (mapper *Mapper3) PPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		*mappedAddr = uint32(mapper.chrBankSelect)*0x2000 + uint32(addr)
+		return true
+	}
+
+	return false
+}
+
+// PPUMapWrite Mapper3's PPUMapWrite implementation.
+func (mapper *Mapper3) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			// CHR RAM
+			*mappedAddr = uint32(addr)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mirror Mapper3's Mirror implementation.
+func (mapper *Mapper3) Mirror() int {
+	return mirrorHardware
+}
+
+// Reset Mapper3's Reset implementation.
+func (mapper *Mapper3) Reset() {
+	mapper.chrBankSelect = 0
+}
+
+// BusConflicts Mapper3's BusConflicts implementation.
+func (mapper *Mapper3) BusConflicts() bool {
+	return true
+}
+
+// StateFields Selected 8KB CHR bank.
+func (mapper *Mapper3) StateFields() []interface{} {
+	return []interface{}{&mapper.chrBankSelect}
+}
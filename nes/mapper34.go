@@ -0,0 +1,136 @@
+package nes
+
+// Mapper34 BNROM and NINA-001 struct, two unrelated boards sharing one mapper
+// number. NES 2.0 submapper 1 is NINA-001 and 2 is BNROM, otherwise NINA-001
+// is told apart by having more than 8KB CHR ROM.
+// See http://wiki.nesdev.com/w/index.php/INES_Mapper_034
+type Mapper34 struct {
+	prgBanks uint8
+	chrBanks uint8
+
+	nina bool
+
+	prgBankSelect   uint8
+	chrBankSelectLo uint8
+	chrBankSelectHi uint8
+}
+
+// NewMapper34 Creates a new mapper of mapper34.
+func NewMapper34(prgBanks uint8, chrBanks uint8) Mapper {
+	return newMapper34(prgBanks, chrBanks, chrBanks > 1)
+}
+
+func newMapper34(prgBanks uint8, chrBanks uint8, nina bool) Mapper {
+	mapper := Mapper34{}
+	mapper.prgBanks = prgBanks
+	mapper.chrBanks = chrBanks
+	mapper.nina = nina
+	mapper.Reset()
+	return &mapper
+}
+
+// Factory of NES 2.0 submapper 1 (NINA-001) or 2 (BNROM).
+func mapper34Board(nina bool) MapperFactory {
+	return func(info CartridgeInfo, prgBanks uint8, chrBanks uint8) Mapper {
+		return newMapper34(prgBanks, chrBanks, nina)
+	}
+}
+
+// 4KB CHR bank selected by a NINA-001 register write.
+func (mapper *Mapper34) chrBank(data uint8) uint8 {
+	banks := uint32(mapper.chrBanks) * 2
+	if banks == 0 {
+		// 8KB CHR RAM
+		banks = 2
+	}
+	return uint8(uint32(data&0x0F) % banks)
+}
+
+// CPUMapRead Mapper34's CPUMapRead implementation.
+func (mapper *Mapper34) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		// NINA-001 has 8KB PRG RAM.
+		if mapper.nina {
+			*mappedAddr = uint32(addr & 0x1FFF)
+			return true
+		}
+	} else if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = uint32(mapper.prgBankSelect)*0x8000 + uint32(addr&0x7FFF)
+		return true
+	}
+
+	return false
+}
+
+// CPUMapWrite Mapper34's CPUMapWrite implementation.
+func (mapper *Mapper34) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if mapper.nina {
+		if addr >= 0x6000 && addr <= 0x7FFF {
+			// Registers live on top of PRG RAM, writes go to both.
+			switch addr {
+			case 0x7FFD:
+				mapper.prgBankSelect = (data & 0x01) % prgBanks32(mapper.prgBanks)
+			case 0x7FFE:
+				mapper.chrBankSelectLo = mapper.chrBank(data)
+			case 0x7FFF:
+				mapper.chrBankSelectHi = mapper.chrBank(data)
+			}
+			*mappedAddr = uint32(addr & 0x1FFF)
+			return true
+		}
+	} else if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mappedRegister
+		mapper.prgBankSelect = data % prgBanks32(mapper.prgBanks)
+		return true
+	}
+
+	return false
+}
+
+// PPUMapRead Mapper34's PPUMapRead implementation.
+func (mapper *Mapper34) PPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x0FFF {
+		*mappedAddr = uint32(mapper.chrBankSelectLo)*0x1000 + uint32(addr&0x0FFF)
+		return true
+	} else if addr >= 0x1000 && addr <= 0x1FFF {
+		*mappedAddr = uint32(mapper.chrBankSelectHi)*0x1000 + uint32(addr&0x0FFF)
+		return true
+	}
+
+	return false
+}
+
+// PPUMapWrite Mapper34's PPUMapWrite implementation.
+func (mapper *Mapper34) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			// CHR RAM
+			*mappedAddr = uint32(addr)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mirror Mapper34's Mirror implementation.
+func (mapper *Mapper34) Mirror() int {
+	return mirrorHardware
+}
+
+// Reset Mapper34's Reset implementation.
+func (mapper *Mapper34) Reset() {
+	mapper.prgBankSelect = 0
+	mapper.chrBankSelectLo = 0
+	mapper.chrBankSelectHi = 1
+}
+
+// BusConflicts Mapper34's BusConflicts implementation, only BNROM has them.
+func (mapper *Mapper34) BusConflicts() bool {
+	return !mapper.nina
+}
+
+// StateFields Selected PRG bank and the two 4KB CHR banks of NINA-001.
+func (mapper *Mapper34) StateFields() []interface{} {
+	return []interface{}{&mapper.prgBankSelect, &mapper.chrBankSelectLo, &mapper.chrBankSelectHi}
+}
@@ -0,0 +1,269 @@
+package nes
+
+// Mapper4 MMC3 (TxROM) struct
+// See http://wiki.nesdev.com/w/index.php/MMC3
+type Mapper4 struct {
+	prgBanks uint8
+	chrBanks uint8
+
+	targetRegister uint8
+	register       [8]uint8 // R0-R5 select CHR banks, R6 and R7 select PRG banks.
+	prgBankMode    bool
+	chrInversion   bool
+	mirror         int
+
+	prgRAMEnabled      bool
+	prgRAMWriteProtect bool
+
+	irqActive  bool
+	irqEnabled bool
+	irqReload  bool
+	irqCounter uint8
+	irqLatch   uint8
+
+	// A12 watcher, MMC3 clocks its scanline counter on the rising edge of PPU A12.
+	a12       bool
+	a12Cycles uint8 // CPU cycles since A12 went low, saturating.
+}
+
+// CPU cycles A12 must stay low before a rising edge counts, real MMC3 filters
+// out short pulses such as the ones between background and sprite fetches.
+const mmc3A12Filter = 3
+
+// NewMapper4 Creates a new mapper of mapper4.
+func NewMapper4(prgBanks uint8, chrBanks uint8) Mapper {
+	mapper := Mapper4{}
+	mapper.prgBanks = prgBanks
+	mapper.chrBanks = chrBanks
+	mapper.Reset()
+	return &mapper
+}
+
+// Get 8KB PRG bank number mapped to the given CPU address.
+func (mapper *Mapper4) prgBank8(addr uint16) uint32 {
+	banks := uint32(mapper.prgBanks) * 2
+	secondLast := banks - 2
+
+	var bank uint32
+	switch (addr >> 13) & 0x03 {
+	case 0: // $8000-$9FFF
+		if mapper.prgBankMode {
+			bank = secondLast
+		} else {
+			bank = uint32(mapper.register[6] & 0x3F)
+		}
+	case 1: // $A000-$BFFF
+		bank = uint32(mapper.register[7] & 0x3F)
+	case 2: // $C000-$DFFF
+		if mapper.prgBankMode {
+			bank = uint32(mapper.register[6] & 0x3F)
+		} else {
+			bank = secondLast
+		}
+	case 3: // $E000-$FFFF
+		bank = banks - 1
+	}
+
+	return bank % banks
+}
+
+// Get 1KB CHR bank number mapped to the given PPU address.
+func (mapper *Mapper4) chrBank1(addr uint16) uint32 {
+	// Inversion swaps the 2KB and 1KB halves.
+	if mapper.chrInversion {
+		addr ^= 0x1000
+	}
+
+	var bank uint32
+	switch {
+	case addr < 0x0800:
+		bank = uint32(mapper.register[0]&0xFE) | uint32((addr>>10)&0x01)
+	case addr < 0x1000:
+		bank = uint32(mapper.register[1]&0xFE) | uint32((addr>>10)&0x01)
+	default:
+		bank = uint32(mapper.register[2+((addr-0x1000)>>10)])
+	}
+
+	return bank % (uint32(mapper.chrBanks) * 8)
+}
+
+// CPUMapRead Mapper4's CPUMapRead implementation.
+func (mapper *Mapper4) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		if mapper.prgRAMEnabled {
+			*mappedAddr = uint32(addr & 0x1FFF)
+			return true
+		}
+	} else if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mapper.prgBank8(addr)*0x2000 + uint32(addr&0x1FFF)
+		return true
+	}
+
+	return false
+}
+
+// CPUMapWrite Mapper4's CPUMapWrite implementation.
+func (mapper *Mapper4) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		if mapper.prgRAMEnabled && !mapper.prgRAMWriteProtect {
+			*mappedAddr = uint32(addr & 0x1FFF)
+			return true
+		}
+	} else if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mappedRegister
+
+		// Registers are selected by address range and whether address is even or odd.
+		even := addr&0x0001 == 0
+		switch {
+		case addr <= 0x9FFF:
+			if even {
+				// Bank select
+				mapper.targetRegister = data & 0x07
+				mapper.prgBankMode = data&0x40 != 0
+				mapper.chrInversion = data&0x80 != 0
+			} else {
+				// Bank data
+				mapper.register[mapper.targetRegister] = data
+			}
+		case addr <= 0xBFFF:
+			if even {
+				// Mirroring
+				if data&0x01 != 0 {
+					mapper.mirror = mirrorHorizontal
+				} else {
+					mapper.mirror = mirrorVertical
+				}
+			} else {
+				// PRG RAM protect
+				mapper.prgRAMEnabled = data&0x80 != 0
+				mapper.prgRAMWriteProtect = data&0x40 != 0
+			}
+		case addr <= 0xDFFF:
+			if even {
+				// IRQ latch
+				mapper.irqLatch = data
+			} else {
+				// IRQ reload
+				mapper.irqCounter = 0
+				mapper.irqReload = true
+			}
+		default:
+			if even {
+				// IRQ disable, which also acknowledges pending interrupt.
+				mapper.irqEnabled = false
+				mapper.irqActive = false
+			} else {
+				// IRQ enable
+				mapper.irqEnabled = true
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// PPUMapRead Mapper4's PPUMapRead implementation.
+func (mapper *Mapper4) PPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			*mappedAddr = uint32(addr)
+		} else {
+			*mappedAddr = mapper.chrBank1(addr)*0x0400 + uint32(addr&0x03FF)
+		}
+		return true
+	}
+
+	return false
+}
+
+// PPUMapWrite Mapper4's PPUMapWrite implementation.
+func (mapper *Mapper4) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			// CHR RAM
+			*mappedAddr = uint32(addr)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mirror Mapper4's Mirror implementation.
+func (mapper *Mapper4) Mirror() int {
+	return mapper.mirror
+}
+
+// Reset Mapper4's Reset implementation.
+func (mapper *Mapper4) Reset() {
+	mapper.targetRegister = 0
+	mapper.register = [8]uint8{0, 2, 4, 5, 6, 7, 0, 1}
+	mapper.prgBankMode = false
+	mapper.chrInversion = false
+	mapper.mirror = mirrorHardware
+
+	// Plenty of games never touch $A001, so keep RAM usable by default.
+	mapper.prgRAMEnabled = true
+	mapper.prgRAMWriteProtect = false
+
+	mapper.irqActive = false
+	mapper.irqEnabled = false
+	mapper.irqReload = false
+	mapper.irqCounter = 0
+	mapper.irqLatch = 0
+
+	mapper.a12 = false
+	mapper.a12Cycles = 0
+}
+
+// IRQState Mapper4's IRQState implementation.
+func (mapper *Mapper4) IRQState() bool {
+	return mapper.irqActive
+}
+
+// PPUAddress Mapper4's PPUAddress implementation, clocks scanline counter on
+// filtered A12 rising edges.
+func (mapper *Mapper4) PPUAddress(addr uint16) {
+	a12 := addr&0x1000 != 0
+
+	if a12 && !mapper.a12 && mapper.a12Cycles >= mmc3A12Filter {
+		mapper.clockScanline()
+	}
+	if !a12 && mapper.a12 {
+		mapper.a12Cycles = 0
+	}
+	mapper.a12 = a12
+}
+
+// CPUCycle Mapper4's CPUCycle implementation, times how long A12 stays low.
+func (mapper *Mapper4) CPUCycle() {
+	if !mapper.a12 && mapper.a12Cycles < 0xFF {
+		mapper.a12Cycles++
+	}
+}
+
+func (mapper *Mapper4) clockScanline() {
+	if mapper.irqCounter == 0 || mapper.irqReload {
+		mapper.irqCounter = mapper.irqLatch
+		mapper.irqReload = false
+	} else {
+		mapper.irqCounter--
+	}
+
+	if mapper.irqCounter == 0 && mapper.irqEnabled {
+		mapper.irqActive = true
+	}
+}
+
+// StateFields Bank select and bank registers, PRG RAM protection, scanline
+// counter and A12 filter.
+func (mapper *Mapper4) StateFields() []interface{} {
+	return []interface{}{
+		&mapper.targetRegister, &mapper.register, &mapper.prgBankMode, &mapper.chrInversion, &mapper.mirror,
+		&mapper.prgRAMEnabled, &mapper.prgRAMWriteProtect,
+		&mapper.irqActive, &mapper.irqEnabled, &mapper.irqReload, &mapper.irqCounter, &mapper.irqLatch,
+		&mapper.a12, &mapper.a12Cycles,
+	}
+}
@@ -0,0 +1,86 @@
+package nes
+
+import "testing"
+
+// TestMapper4IRQCounter Drive the scanline counter by hand through A12 edges
+// and CPU cycles.
+func TestMapper4IRQCounter(t *testing.T) {
+	mapper := NewMapper4(2, 1).(*Mapper4)
+	write := func(addr uint16, data uint8) {
+		var mappedAddr uint32
+		mapper.CPUMapWrite(addr, &mappedAddr, data)
+	}
+	// A12 low for some CPU cycles, then a rising edge.
+	edge := func(lowCycles int) {
+		mapper.PPUAddress(0x0000)
+		for i := 0; i < lowCycles; i++ {
+			mapper.CPUCycle()
+		}
+		mapper.PPUAddress(0x1000)
+	}
+
+	write(0xC000, 2) // Latch
+	write(0xC001, 0) // Reload
+	write(0xE001, 0) // Enable
+
+	steps := []struct {
+		lowCycles int
+		counter   uint8
+		irq       bool
+	}{
+		{10, 2, false}, // Reload from latch.
+		{10, 1, false},
+		{2, 1, false}, // Too short, filtered out.
+		{mmc3A12Filter, 0, true},
+		{10, 2, true}, // Reload, IRQ stays pending until acknowledged.
+	}
+	for i, step := range steps {
+		edge(step.lowCycles)
+		if mapper.irqCounter != step.counter || mapper.IRQState() != step.irq {
+			t.Fatalf("edge %d: counter %d irq %t, expected %d %t", i, mapper.irqCounter, mapper.IRQState(), step.counter, step.irq)
+		}
+	}
+
+	write(0xE000, 0) // Disable and acknowledge
+	if mapper.IRQState() {
+		t.Errorf("IRQ still pending after $E000 write")
+	}
+}
+
+// TestMapper4IRQTiming With background at $0000 and sprites at $1000, the
+// counter is clocked once per rendered scanline, on the first sprite pattern
+// fetch at dot 261.
+func TestMapper4IRQTiming(t *testing.T) {
+	code := []byte{
+		0xA9, 0x08, 0x8D, 0x00, 0x20, // LDA #$08, STA $2000 ; sprites at $1000
+		0xA9, 0x18, 0x8D, 0x01, 0x20, // LDA #$18, STA $2001 ; render everything
+		0xA9, 0xFF, 0x8D, 0x00, 0xC0, // LDA #$FF, STA $C000 ; latch
+		0x8D, 0x01, 0xC0, // STA $C001 ; reload
+		0x4C, 0x12, 0x80, // JMP $8012
+	}
+	bus, err := newTestBus(makeROM(4, 2, 1, 0, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapper := bus.cartridge.mapper.(*Mapper4)
+
+	bus.RunFrame()
+	clocks := 0
+	counter := mapper.irqCounter
+	for !bus.PPU.FrameComplete {
+		bus.Clock()
+		if mapper.irqCounter == counter {
+			continue
+		}
+		counter = mapper.irqCounter
+		clocks++
+		if dot := bus.PPU.cycle - 1; dot != 261 {
+			t.Fatalf("counter clocked at scanline %d dot %d, expected dot 261", bus.PPU.scanline, dot)
+		}
+	}
+
+	// Pre-render scanline and 240 visible ones.
+	if clocks != 241 {
+		t.Errorf("counter clocked %d times in a frame, expected 241", clocks)
+	}
+}
@@ -0,0 +1,90 @@
+package nes
+
+// Mapper66 GxROM struct
+// See http://wiki.nesdev.com/w/index.php/GxROM
+type Mapper66 struct {
+	prgBanks uint8
+	chrBanks uint8
+
+	prgBankSelect uint8
+	chrBankSelect uint8
+}
+
+// NewMapper66 Creates a new mapper of mapper66.
+func NewMapper66(prgBanks uint8, chrBanks uint8) Mapper {
+	mapper := Mapper66{}
+	mapper.prgBanks = prgBanks
+	mapper.chrBanks = chrBanks
+	mapper.Reset()
+	return &mapper
+}
+
+// CPUMapRead Mapper66's CPUMapRead implementation.
+func (mapper *Mapper66) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = uint32(mapper.prgBankSelect)*0x8000 + uint32(addr&0x7FFF)
+		return true
+	}
+
+	return false
+}
+
+// CPUMapWrite Mapper66's CPUMapWrite implementation.
+func (mapper *Mapper66) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mappedRegister
+
+		// xxPP xxCC, 32KB PRG bank and 8KB CHR bank.
+		mapper.prgBankSelect = ((data >> 4) & 0x03) % prgBanks32(mapper.prgBanks)
+		if mapper.chrBanks > 0 {
+			mapper.chrBankSelect = (data & 0x03) % mapper.chrBanks
+		}
+		return true
+	}
+
+	return false
+}
+
+// PPUMapRead Mapper66's PPUMapRead implementation.
+func (mapper *Mapper66) PPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		*mappedAddr = uint32(mapper.chrBankSelect)*0x2000 + uint32(addr)
+		return true
+	}
+
+	return false
+}
+
+// PPUMapWrite Mapper66's PPUMapWrite implementation.
+func (mapper *Mapper66) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			// CHR RAM
+			*mappedAddr = uint32(addr)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mirror Mapper66's Mirror implementation.
+func (mapper *Mapper66) Mirror() int {
+	return mirrorHardware
+}
+
+// Reset Mapper66's Reset implementation.
+func (mapper *Mapper66) Reset() {
+	mapper.prgBankSelect = 0
+	mapper.chrBankSelect = 0
+}
+
+// BusConflicts Mapper66's BusConflicts implementation.
+func (mapper *Mapper66) BusConflicts() bool {
+	return true
+}
+
+// StateFields Selected PRG and CHR banks.
+func (mapper *Mapper66) StateFields() []interface{} {
+	return []interface{}{&mapper.prgBankSelect, &mapper.chrBankSelect}
+}
@@ -0,0 +1,90 @@
+package nes
+
+// Mapper7 AxROM struct
+// See http://wiki.nesdev.com/w/index.php/AxROM
+//
+// Only AMROM and AOROM have bus conflicts, ANROM doesn't and games written for
+// it rely on that, so they are left out here.
+type Mapper7 struct {
+	prgBanks uint8
+	chrBanks uint8
+
+	prgBankSelect uint8
+	mirror        int
+}
+
+// NewMapper7 Creates a new mapper of mapper7.
+func NewMapper7(prgBanks uint8, chrBanks uint8) Mapper {
+	mapper := Mapper7{}
+	mapper.prgBanks = prgBanks
+	mapper.chrBanks = chrBanks
+	mapper.Reset()
+	return &mapper
+}
+
+// CPUMapRead Mapper7's CPUMapRead implementation.
+func (mapper *Mapper7) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = uint32(mapper.prgBankSelect)*0x8000 + uint32(addr&0x7FFF)
+		return true
+	}
+
+	return false
+}
+
+// CPUMapWrite Mapper7's CPUMapWrite implementation.
+func (mapper *Mapper7) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x8000 && addr <= 0xFFFF {
+		*mappedAddr = mappedRegister
+
+		// xxxM xPPP, one-screen page select and 32KB PRG bank.
+		mapper.prgBankSelect = (data & 0x07) % prgBanks32(mapper.prgBanks)
+		if data&0x10 != 0 {
+			mapper.mirror = oneScrennHi
+		} else {
+			mapper.mirror = oneScreenLo
+		}
+		return true
+	}
+
+	return false
+}
+
+// PPUMapRead Mapper7's PPUMapRead implementation.
+func (mapper *Mapper7) PPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		*mappedAddr = uint32(addr)
+		return true
+	}
+
+	return false
+}
+
+// PPUMapWrite Mapper7's PPUMapWrite implementation.
+func (mapper *Mapper7) PPUMapWrite(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x0000 && addr <= 0x1FFF {
+		if mapper.chrBanks == 0 {
+			// CHR RAM
+			*mappedAddr = uint32(addr)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mirror Mapper7's Mirror implementation.
+func (mapper *Mapper7) Mirror() int {
+	return mapper.mirror
+}
+
+// Reset Mapper7's Reset implementation.
+func (mapper *Mapper7) Reset() {
+	mapper.prgBankSelect = 0
+	mapper.mirror = oneScreenLo
+}
+
+// StateFields Selected 32KB PRG bank and one-screen page.
+func (mapper *Mapper7) StateFields() []interface{} {
+	return []interface{}{&mapper.prgBankSelect, &mapper.mirror}
+}
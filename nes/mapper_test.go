@@ -0,0 +1,237 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Mapper made by a test factory, remembering what it was created with.
+type factoryMapper struct {
+	Mapper
+	name string
+	info CartridgeInfo
+}
+
+// NES 2.0 image of a 12-bit mapper and submapper.
+func makeNES20ROM(mapper uint16, submapper uint8) []byte {
+	rom := makeROM(uint8(mapper), 1, 1, 0, nil)
+	rom[7] = uint8(mapper)&0xF0 | 0x08
+	rom[8] = submapper<<4 | uint8(mapper>>8)
+	return rom
+}
+
+// TestRegisterMapper Submappers without a factory of their own fall back to
+// submapper 0, factories get the board description.
+func TestRegisterMapper(t *testing.T) {
+	const id = 0x1F0
+	factory := func(name string) MapperFactory {
+		return func(info CartridgeInfo, prgBanks uint8, chrBanks uint8) Mapper {
+			return &factoryMapper{NewMapper0(prgBanks, chrBanks), name, info}
+		}
+	}
+	RegisterMapper(id, 0, factory("default"))
+	RegisterMapper(id, 3, factory("sub3"))
+	defer RegisterMapper(id, 0, nil)
+	defer RegisterMapper(id, 3, nil)
+
+	tests := []struct {
+		submapper uint8
+		name      string
+	}{
+		{0, "default"},
+		{3, "sub3"},
+		{5, "default"},
+	}
+	for _, test := range tests {
+		cart, err := NewCartridgeFromBytes(makeNES20ROM(id, test.submapper))
+		if err != nil {
+			t.Fatalf("submapper %d: %v", test.submapper, err)
+		}
+		mapper := cart.mapper.(*factoryMapper)
+		if mapper.name != test.name {
+			t.Errorf("submapper %d: got %s factory, expected %s", test.submapper, mapper.name, test.name)
+		}
+		if mapper.info.Mapper != id || mapper.info.Submapper != test.submapper {
+			t.Errorf("submapper %d: factory got mapper %d.%d", test.submapper, mapper.info.Mapper, mapper.info.Submapper)
+		}
+	}
+
+	// Removing the fallback leaves submapper 5 unsupported.
+	RegisterMapper(id, 0, nil)
+	_, err := NewCartridgeFromBytes(makeNES20ROM(id, 5))
+	if !errors.Is(err, ErrUnsupportedMapper) {
+		t.Fatalf("got %v, expected ErrUnsupportedMapper", err)
+	}
+	var mapperErr *MapperError
+	if !errors.As(err, &mapperErr) || mapperErr.Mapper != id || mapperErr.Submapper != 5 {
+		t.Errorf("got %#v, expected MapperError for %d.5", mapperErr, id)
+	}
+	var romErr *ROMError
+	if !errors.As(err, &romErr) || romErr.Section != "mapper" {
+		t.Errorf("got %v, expected ROMError of mapper section", err)
+	}
+}
+
+// Current values of mapper registers.
+func mapperStateValues(mapper Mapper) []interface{} {
+	var values []interface{}
+	for _, field := range mapper.StateFields() {
+		values = append(values, reflect.ValueOf(field).Elem().Interface())
+	}
+	return values
+}
+
+// TestMapperStateRoundTrip Registers changed by writes survive a save state,
+// reset and load.
+func TestMapperStateRoundTrip(t *testing.T) {
+	type write struct {
+		addr uint16
+		data uint8
+	}
+	// MMC1 takes a register value as 5 serial writes, low bit first.
+	mmc1 := func(addr uint16, value uint8) []write {
+		var writes []write
+		for i := uint(0); i < 5; i++ {
+			writes = append(writes, write{addr, value >> i & 0x01})
+		}
+		return writes
+	}
+
+	tests := []struct {
+		mapper   uint8
+		chrBanks uint8
+		writes   []write
+	}{
+		{1, 4, append(append(mmc1(0x8000, 0x12), mmc1(0xA000, 0x05)...), append(mmc1(0xE000, 0x03), write{0x8000, 0x01})...)},
+		{2, 1, []write{{0x8000, 0x05}}},
+		{3, 4, []write{{0x8000, 0x03}}},
+		{4, 4, []write{
+			{0x8000, 0x46}, {0x8001, 0x05}, {0xA000, 0x01}, {0xA001, 0xC0},
+			{0xC000, 0x10}, {0xC001, 0x00}, {0xE001, 0x00},
+		}},
+		{7, 1, []write{{0x8000, 0x13}}},
+		{11, 4, []write{{0x8000, 0x31}}},
+		{34, 1, []write{{0x8000, 0x03}}},
+		{34, 4, []write{{0x7FFD, 0x01}, {0x7FFE, 0x05}, {0x7FFF, 0x06}}},
+		{66, 4, []write{{0x8000, 0x23}}},
+	}
+
+	for _, test := range tests {
+		bus, err := newTestBus(makeROM(test.mapper, 8, test.chrBanks, 0, nil))
+		if err != nil {
+			t.Fatalf("mapper %d: %v", test.mapper, err)
+		}
+		mapper := bus.cartridge.mapper
+		watcher, _ := mapper.(CPUCycleWatcher)
+		fresh := mapperStateValues(mapper)
+
+		var mappedAddr uint32
+		for _, write := range test.writes {
+			mapper.CPUMapWrite(write.addr, &mappedAddr, write.data)
+			for i := 0; watcher != nil && i < 2; i++ {
+				watcher.CPUCycle()
+			}
+		}
+		if ppuWatcher, ok := mapper.(PPUAddressWatcher); ok {
+			// Clock MMC3 scanline counter through A12.
+			for i := 0; i < 3; i++ {
+				ppuWatcher.PPUAddress(0x0000)
+				for j := 0; j < mmc3A12Filter; j++ {
+					watcher.CPUCycle()
+				}
+				ppuWatcher.PPUAddress(0x1000)
+			}
+		}
+		saved := mapperStateValues(mapper)
+		if reflect.DeepEqual(saved, fresh) {
+			t.Fatalf("mapper %d: writes didn't change any register", test.mapper)
+		}
+
+		var state bytes.Buffer
+		if err := bus.SaveState(&state); err != nil {
+			t.Fatalf("mapper %d: %v", test.mapper, err)
+		}
+		bus.Reset()
+		if reflect.DeepEqual(mapperStateValues(mapper), saved) {
+			t.Fatalf("mapper %d: reset didn't change any register", test.mapper)
+		}
+		if err := bus.LoadState(&state); err != nil {
+			t.Fatalf("mapper %d: %v", test.mapper, err)
+		}
+		if loaded := mapperStateValues(mapper); !reflect.DeepEqual(loaded, saved) {
+			t.Errorf("mapper %d: got %v after load, expected %v", test.mapper, loaded, saved)
+		}
+	}
+}
+
+// TestLargeCHRROM Bank numbers wrap around CHR ROM of 128 banks and more
+// instead of overflowing.
+func TestLargeCHRROM(t *testing.T) {
+	for _, chrBanks := range []uint8{128, 255} {
+		bus, err := newTestBus(makeROM(1, 2, chrBanks, 0, nil))
+		if err != nil {
+			t.Fatalf("%d CHR banks: %v", chrBanks, err)
+		}
+		// Number each 4KB bank.
+		chr := bus.cartridge.chrMemory
+		for i := range chr {
+			chr[i] = uint8(i / 4096)
+		}
+
+		// 4KB CHR mode, bank 31 at $0000.
+		mapper := bus.cartridge.mapper.(*Mapper1)
+		mapper.controlRegister, mapper.chrBank0 = 0x1C, 31
+		if data := bus.PPU.PPURead(0x0000); data != 31 {
+			t.Errorf("%d CHR banks: $0000 = %d, expected bank 31", chrBanks, data)
+		}
+	}
+}
+
+// TestMapper34Board NES 2.0 submapper picks the board, iNES headers go by CHR
+// ROM size. NINA-001 CHR registers work with CHR ROM of 128 banks.
+func TestMapper34Board(t *testing.T) {
+	nes20 := func(submapper uint8, chrBanks uint8) []byte {
+		rom := makeROM(34, 2, chrBanks, 0, nil)
+		rom[7], rom[8] = 0x20|0x08, submapper<<4
+		return rom
+	}
+	tests := []struct {
+		name string
+		rom  []byte
+		nina bool
+	}{
+		{"iNES 8KB CHR", makeROM(34, 2, 1, 0, nil), false},
+		{"iNES 16KB CHR", makeROM(34, 2, 2, 0, nil), true},
+		{"iNES 1MB CHR", makeROM(34, 2, 128, 0, nil), true},
+		{"submapper 1", nes20(1, 1), true},
+		{"submapper 2", nes20(2, 2), false},
+		{"submapper 1 1MB CHR", nes20(1, 128), true},
+	}
+
+	for _, test := range tests {
+		bus, err := newTestBus(test.rom)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		mapper := bus.cartridge.mapper.(*Mapper34)
+		if mapper.nina != test.nina {
+			t.Errorf("%s: NINA-001 %t, expected %t", test.name, mapper.nina, test.nina)
+			continue
+		}
+		if !test.nina {
+			continue
+		}
+
+		chr := bus.cartridge.chrMemory
+		for i := range chr {
+			chr[i] = uint8(i / 4096)
+		}
+		bus.CPUWrite(0x7FFE, 0x0F)
+		expected := uint8(0x0F % (len(chr) / 4096))
+		if data := bus.PPU.PPURead(0x0000); data != expected {
+			t.Errorf("%s: $0000 = %d, expected bank %d", test.name, data, expected)
+		}
+	}
+}
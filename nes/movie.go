@@ -0,0 +1,350 @@
+package nes
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Movie frame commands.
+const (
+	MovieSoftReset = (1 << 0)
+	MovieHardReset = (1 << 1)
+)
+
+// Button order of a FM2 pad field, Right is bit 0 up to A at bit 7.
+const movieButtons = "RLDUTSBA"
+
+// MovieFrame Input of one frame.
+type MovieFrame struct {
+	Commands uint8
+	Pads     [2]uint8
+}
+
+// Movie Controller input recorded frame by frame, read and written in
+// FCEUX's FM2 text format.
+type Movie struct {
+	Version       int
+	EmuVersion    int
+	RerecordCount int
+	PAL           bool
+	ROMFilename   string
+	ROMChecksum   [16]byte // MD5 of PRG and CHR ROM.
+	GUID          string
+	Comments      []string
+
+	// Save state movie starts from, nil means power-on.
+	SaveState []byte
+
+	Frames []MovieFrame
+}
+
+// NewMovie Create an empty movie for cart, starting from power-on unless a
+// save state is given.
+func NewMovie(cart *Cartridge, saveState []byte) *Movie {
+	movie := Movie{}
+	movie.Version = 3
+	movie.ROMFilename = cart.Title()
+	movie.ROMChecksum = cart.MD5()
+	movie.GUID = newMovieGUID()
+	movie.Comments = []string{"author GoNES " + Version}
+	movie.SaveState = saveState
+	return &movie
+}
+
+func newMovieGUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	s := strings.ToUpper(hex.EncodeToString(b[:]))
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// ReadMovie Read a FM2 movie.
+func ReadMovie(r io.Reader) (*Movie, error) {
+	movie := Movie{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024) // Save states are long lines.
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+
+		if text[0] == '|' {
+			frame, err := parseMovieFrame(text)
+			if err != nil {
+				return nil, fmt.Errorf("movie line %d: %w", line, err)
+			}
+			movie.Frames = append(movie.Frames, frame)
+			continue
+		}
+
+		key, value := text, ""
+		if i := strings.IndexByte(text, ' '); i >= 0 {
+			key, value = text[:i], text[i+1:]
+		}
+		if err := movie.setHeader(key, value); err != nil {
+			return nil, fmt.Errorf("movie line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+func (movie *Movie) setHeader(key string, value string) error {
+	var err error
+	switch key {
+	case "version":
+		movie.Version, err = strconv.Atoi(value)
+	case "emuVersion":
+		movie.EmuVersion, err = strconv.Atoi(value)
+	case "rerecordCount":
+		movie.RerecordCount, err = strconv.Atoi(value)
+	case "palFlag":
+		movie.PAL = value == "1"
+	case "romFilename":
+		movie.ROMFilename = value
+	case "romChecksum":
+		var sum []byte
+		if sum, err = decodeMovieBinary(value); err == nil {
+			if len(sum) != len(movie.ROMChecksum) {
+				return fmt.Errorf("bad romChecksum length %d", len(sum))
+			}
+			copy(movie.ROMChecksum[:], sum)
+		}
+	case "guid":
+		movie.GUID = value
+	case "comment":
+		movie.Comments = append(movie.Comments, value)
+	case "savestate":
+		movie.SaveState, err = decodeMovieBinary(value)
+	case "fourscore":
+		if value == "1" {
+			return fmt.Errorf("four score movies are not supported")
+		}
+	case "port2":
+		if value != "0" {
+			return fmt.Errorf("expansion port devices are not supported")
+		}
+	case "FDS":
+		if value == "1" {
+			return fmt.Errorf("FDS movies are not supported")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("bad %s: %w", key, err)
+	}
+	// Other keys (subtitles, port types, binary...) don't matter for playback.
+	return nil
+}
+
+// Binary header values are "base64:..." or hex with "0x" prefix.
+func decodeMovieBinary(value string) ([]byte, error) {
+	if strings.HasPrefix(value, "base64:") {
+		return base64.StdEncoding.DecodeString(value[len("base64:"):])
+	}
+	if strings.HasPrefix(value, "0x") {
+		return hex.DecodeString(value[2:])
+	}
+	return nil, fmt.Errorf("unknown encoding")
+}
+
+// Parse "|commands|port0|port1|port2|".
+func parseMovieFrame(text string) (MovieFrame, error) {
+	frame := MovieFrame{}
+	fields := strings.Split(text, "|")
+	if len(fields) < 4 {
+		return frame, fmt.Errorf("bad input line %q", text)
+	}
+
+	commands, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return frame, fmt.Errorf("bad commands %q", fields[1])
+	}
+	frame.Commands = uint8(commands)
+
+	for port := 0; port < 2; port++ {
+		pad := fields[port+2]
+		if pad == "" {
+			continue
+		}
+		if len(pad) != len(movieButtons) {
+			return frame, fmt.Errorf("bad pad %q", pad)
+		}
+		for i := 0; i < len(movieButtons); i++ {
+			if pad[i] != '.' && pad[i] != ' ' {
+				frame.Pads[port] |= 1 << uint(i)
+			}
+		}
+	}
+	return frame, nil
+}
+
+func formatMoviePad(pad uint8) string {
+	var b [len(movieButtons)]byte
+	for i := range b {
+		if pad&(1<<uint(i)) != 0 {
+			b[i] = movieButtons[i]
+		} else {
+			b[i] = '.'
+		}
+	}
+	return string(b[:])
+}
+
+// Write Write movie in FM2 format.
+func (movie *Movie) Write(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	palFlag := 0
+	if movie.PAL {
+		palFlag = 1
+	}
+	fmt.Fprintf(out, "version %d\n", movie.Version)
+	fmt.Fprintf(out, "emuVersion %d\n", movie.EmuVersion)
+	fmt.Fprintf(out, "rerecordCount %d\n", movie.RerecordCount)
+	fmt.Fprintf(out, "palFlag %d\n", palFlag)
+	fmt.Fprintf(out, "romFilename %s\n", movie.ROMFilename)
+	fmt.Fprintf(out, "romChecksum base64:%s\n", base64.StdEncoding.EncodeToString(movie.ROMChecksum[:]))
+	fmt.Fprintf(out, "guid %s\n", movie.GUID)
+	fmt.Fprintf(out, "fourscore 0\nmicrophone 0\nport0 1\nport1 1\nport2 0\nFDS 0\nNewPPU 0\n")
+	for _, comment := range movie.Comments {
+		fmt.Fprintf(out, "comment %s\n", comment)
+	}
+	if movie.SaveState != nil {
+		fmt.Fprintf(out, "savestate base64:%s\n", base64.StdEncoding.EncodeToString(movie.SaveState))
+	}
+
+	for _, frame := range movie.Frames {
+		fmt.Fprintf(out, "|%d|%s|%s||\n", frame.Commands, formatMoviePad(frame.Pads[0]), formatMoviePad(frame.Pads[1]))
+	}
+	return out.Flush()
+}
+
+// CheckROM Check if movie was recorded with cart.
+func (movie *Movie) CheckROM(cart *Cartridge) error {
+	if sum := cart.MD5(); sum != movie.ROMChecksum {
+		return fmt.Errorf("movie was recorded with ROM %s (MD5 %X), not this one (MD5 %X)",
+			movie.ROMFilename, movie.ROMChecksum, sum)
+	}
+	return nil
+}
+
+// Start Bring bus to where movie starts, power-on or its save state. Power-on
+// clears battery-backed RAM as FCEUX does, so the player's .sav doesn't change
+// what the movie does. Cartridge stops writing its .sav either way, RAM of
+// the movie must not replace the player's save.
+func (movie *Movie) Start(bus *Bus) error {
+	bus.cartridge.SetSavePath("")
+	if movie.SaveState != nil {
+		return bus.LoadState(bytes.NewReader(movie.SaveState))
+	}
+	bus.cartridge.clearSaveRAM()
+	return bus.PowerCycle()
+}
+
+// Apply Run commands of a frame and set controllers, before it is emulated.
+func (frame MovieFrame) Apply(bus *Bus) error {
+	if frame.Commands&MovieHardReset != 0 {
+		if err := bus.PowerCycle(); err != nil {
+			return err
+		}
+	} else if frame.Commands&MovieSoftReset != 0 {
+		bus.Reset()
+	}
+	bus.Controller[0] = frame.Pads[0]
+	bus.Controller[1] = frame.Pads[1]
+	return nil
+}
+
+// MoviePlayer Feeds a movie into a bus frame by frame.
+type MoviePlayer struct {
+	movie *Movie
+	bus   *Bus
+	frame int
+}
+
+// NewMoviePlayer Start playing movie on bus.
+func NewMoviePlayer(bus *Bus, movie *Movie) (*MoviePlayer, error) {
+	if err := movie.Start(bus); err != nil {
+		return nil, err
+	}
+	return &MoviePlayer{movie: movie, bus: bus}, nil
+}
+
+// Frame Apply input of next frame, call before emulating it. Returns false
+// once the movie is over.
+func (player *MoviePlayer) Frame() (bool, error) {
+	if player.frame >= len(player.movie.Frames) {
+		return false, nil
+	}
+	frame := player.movie.Frames[player.frame]
+	player.frame++
+	return true, frame.Apply(player.bus)
+}
+
+// Position Number of frames played.
+func (player *MoviePlayer) Position() int {
+	return player.frame
+}
+
+// MovieRecorder Records input of a bus frame by frame.
+type MovieRecorder struct {
+	movie *Movie
+	bus   *Bus
+}
+
+// NewMovieRecorder Start recording on bus, from power-on or, if fromState is
+// true, from the current machine state.
+func NewMovieRecorder(bus *Bus, fromState bool) (*MovieRecorder, error) {
+	var state []byte
+	if fromState {
+		var buf bytes.Buffer
+		if err := bus.SaveState(&buf); err != nil {
+			return nil, err
+		}
+		state = buf.Bytes()
+	}
+
+	movie := NewMovie(bus.cartridge, state)
+	if err := movie.Start(bus); err != nil {
+		return nil, err
+	}
+	return &MovieRecorder{movie: movie, bus: bus}, nil
+}
+
+// Frame Record controllers and commands of next frame, call before emulating
+// it. Commands are run on bus.
+func (recorder *MovieRecorder) Frame(commands uint8) error {
+	frame := MovieFrame{Commands: commands, Pads: [2]uint8{recorder.bus.Controller[0], recorder.bus.Controller[1]}}
+	recorder.movie.Frames = append(recorder.movie.Frames, frame)
+	return frame.Apply(recorder.bus)
+}
+
+// Rerecord Drop frames recorded after frame, after a save state of that
+// point was loaded.
+func (recorder *MovieRecorder) Rerecord(frame int) {
+	if frame < len(recorder.movie.Frames) {
+		recorder.movie.Frames = recorder.movie.Frames[:frame]
+	}
+	recorder.movie.RerecordCount++
+}
+
+// Position Number of frames recorded.
+func (recorder *MovieRecorder) Position() int {
+	return len(recorder.movie.Frames)
+}
+
+// Movie Return movie recorded so far.
+func (recorder *MovieRecorder) Movie() *Movie {
+	return recorder.movie
+}
@@ -0,0 +1,186 @@
+package nes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func saveStateBytes(t *testing.T, bus *Bus) []byte {
+	var state bytes.Buffer
+	if err := bus.SaveState(&state); err != nil {
+		t.Fatalf("saving state: %v", err)
+	}
+	return state.Bytes()
+}
+
+// TestMovieDeterminism Record a movie, write and read it back, then check
+// playback ends in the same machine state on a fresh bus and on a bus that
+// already ran something else.
+func TestMovieDeterminism(t *testing.T) {
+	cart, err := NewCartridge(nestestROM)
+	if err != nil {
+		t.Fatalf("loading %s: %v", nestestROM, err)
+	}
+
+	// Power cycling a used bus must give the same machine as a new one.
+	fresh := NewBus()
+	fresh.InsertCartridge(cart)
+	fresh.Reset()
+	used := NewBus()
+	used.InsertCartridge(cart)
+	used.Reset()
+	used.Controller[0] = ButtonStart
+	for i := 0; i < 60; i++ {
+		used.RunFrame()
+	}
+	if err := used.PowerCycle(); err != nil {
+		t.Fatalf("power cycle: %v", err)
+	}
+	if !bytes.Equal(saveStateBytes(t, fresh), saveStateBytes(t, used)) {
+		t.Fatalf("power cycled bus differs from a new one")
+	}
+
+	// Record, pressing START for a few frames so nestest runs its tests.
+	bus := NewBus()
+	bus.InsertCartridge(cart)
+	bus.Reset()
+	recorder, err := NewMovieRecorder(bus, false)
+	if err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	for frame := 0; frame < 180; frame++ {
+		bus.Controller[0] = 0
+		if frame >= 30 && frame < 35 {
+			bus.Controller[0] = ButtonStart
+		}
+		var commands uint8
+		if frame == 120 {
+			commands = MovieSoftReset
+		}
+		if err := recorder.Frame(commands); err != nil {
+			t.Fatalf("recording frame %d: %v", frame, err)
+		}
+		bus.RunFrame()
+	}
+	recorded := saveStateBytes(t, bus)
+
+	var file bytes.Buffer
+	if err := recorder.Movie().Write(&file); err != nil {
+		t.Fatalf("writing movie: %v", err)
+	}
+	movie, err := ReadMovie(&file)
+	if err != nil {
+		t.Fatalf("reading movie: %v", err)
+	}
+	if err := movie.CheckROM(cart); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(movie.Frames) != 180 {
+		t.Fatalf("movie has %d frames, expected 180", len(movie.Frames))
+	}
+
+	for name, bus := range map[string]*Bus{"fresh": fresh, "used": used} {
+		player, err := NewMoviePlayer(bus, movie)
+		if err != nil {
+			t.Fatalf("%s: playing: %v", name, err)
+		}
+		for {
+			playing, err := player.Frame()
+			if err != nil {
+				t.Fatalf("%s: playing frame %d: %v", name, player.Position(), err)
+			}
+			if !playing {
+				break
+			}
+			bus.RunFrame()
+		}
+		if !bytes.Equal(saveStateBytes(t, bus), recorded) {
+			t.Errorf("%s: playback ends in a different state than recording", name)
+		}
+	}
+}
+
+// TestMovieBatterySave Movies from power-on start with battery RAM cleared,
+// whatever the .sav holds, and never write the .sav.
+func TestMovieBatterySave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gones")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	code := []byte{
+		0xAD, 0x00, 0x60, 0x85, 0x00, // LDA $6000, STA $00
+		0xEE, 0x00, 0x60, // INC $6000
+		0x4C, 0x00, 0x80, // JMP $8000
+	}
+	romPath := filepath.Join(dir, "battery.nes")
+	savePath := filepath.Join(dir, "battery.sav")
+	if err := ioutil.WriteFile(romPath, makeROM(0, 1, 1, 0x02, code), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run movie of 10 frames on a cartridge whose .sav is filled with fill.
+	run := func(fill uint8, movie *Movie) (*Movie, []byte) {
+		if err := ioutil.WriteFile(savePath, bytes.Repeat([]byte{fill}, 8192), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cart, err := NewCartridge(romPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bus := NewBus()
+		bus.InsertCartridge(cart)
+		bus.Reset()
+
+		if movie == nil {
+			recorder, err := NewMovieRecorder(bus, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for frame := 0; frame < 10; frame++ {
+				if err := recorder.Frame(0); err != nil {
+					t.Fatal(err)
+				}
+				bus.RunFrame()
+			}
+			movie = recorder.Movie()
+		} else {
+			player, err := NewMoviePlayer(bus, movie)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for {
+				playing, err := player.Frame()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !playing {
+					break
+				}
+				bus.RunFrame()
+			}
+		}
+
+		if err := cart.Save(); err != nil {
+			t.Fatal(err)
+		}
+		save, err := ioutil.ReadFile(savePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(save, bytes.Repeat([]byte{fill}, 8192)) {
+			t.Errorf(".sav filled with $%02X was written during the movie", fill)
+		}
+		return movie, saveStateBytes(t, bus)
+	}
+
+	movie, recorded := run(0x77, nil)
+	_, played := run(0x33, movie)
+	if !bytes.Equal(recorded, played) {
+		t.Errorf("playback with another .sav ends in a different state")
+	}
+}
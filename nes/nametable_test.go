@@ -0,0 +1,141 @@
+package nes
+
+import "testing"
+
+// Mapper wiring each name table to what the test sets, like MMC5 and
+// Namco 163 do with their registers.
+type nameTableTestMapper struct {
+	Mapper
+	sources [4]int
+	pages   [4]uint32
+}
+
+func (mapper *nameTableTestMapper) MapNameTable(table uint16) (int, uint32) {
+	return mapper.sources[table], mapper.pages[table]
+}
+
+// Bus with a nameTableTestMapper cartridge. CHR is 8KB of ROM, or RAM if
+// chrBanks is 0, filled with number of its 1KB page.
+func newNameTableTestBus(t *testing.T, chrBanks uint8) (*Bus, *nameTableTestMapper) {
+	const id = 0x1F1
+	var mapper *nameTableTestMapper
+	RegisterMapper(id, 0, func(info CartridgeInfo, prgBanks uint8, chrBanks uint8) Mapper {
+		mapper = &nameTableTestMapper{Mapper: NewMapper0(prgBanks, chrBanks)}
+		return mapper
+	})
+	defer RegisterMapper(id, 0, nil)
+
+	rom := makeNES20ROM(id, 0)
+	if chrBanks == 0 {
+		rom[5], rom[11] = 0, 0x07 // 8KB CHR RAM
+		rom = rom[:len(rom)-8192]
+	}
+	bus, err := newTestBus(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range bus.cartridge.chrMemory {
+		bus.cartridge.chrMemory[i] = uint8(i / 1024)
+	}
+	return bus, mapper
+}
+
+// TestNameTableMapper Each name table comes from where the mapper says, at
+// the time of the access.
+func TestNameTableMapper(t *testing.T) {
+	bus, mapper := newNameTableTestBus(t, 1)
+	ppu := bus.PPU
+	if len(bus.cartridge.vram) != 2048 {
+		t.Fatalf("got %d bytes of cartridge VRAM, expected 2048", len(bus.cartridge.vram))
+	}
+
+	mapper.sources = [4]int{NameTableCIRAM, NameTableCartridgeRAM, NameTableCHR, NameTableCIRAM}
+	mapper.pages = [4]uint32{1, 1, 3, 0}
+
+	ppu.PPUWrite(0x2005, 0x11)
+	ppu.PPUWrite(0x2405, 0x22)
+	ppu.PPUWrite(0x2805, 0x33) // CHR ROM, ignored
+	ppu.PPUWrite(0x2C05, 0x44)
+
+	if ppu.TableName[1][5] != 0x11 || ppu.TableName[0][5] != 0x44 {
+		t.Errorf("CIRAM got $%02X $%02X, expected $11 $44", ppu.TableName[1][5], ppu.TableName[0][5])
+	}
+	if bus.cartridge.vram[1024+5] != 0x22 {
+		t.Errorf("cartridge VRAM page 1 got $%02X, expected $22", bus.cartridge.vram[1024+5])
+	}
+	if bus.cartridge.chrMemory[3*1024+5] != 3 {
+		t.Errorf("CHR ROM written through name table 2")
+	}
+
+	reads := []struct {
+		addr uint16
+		data uint8
+	}{
+		{0x2005, 0x11},
+		{0x2405, 0x22},
+		{0x2805, 0x03},
+		{0x2C05, 0x44},
+		{0x3005, 0x11}, // $3000-$3EFF mirrors $2000-$2EFF
+		{0x3805, 0x03},
+	}
+	for _, read := range reads {
+		if data := ppu.PPURead(read.addr); data != read.data {
+			t.Errorf("$%04X = $%02X, expected $%02X", read.addr, data, read.data)
+		}
+	}
+
+	// Mapping changes take effect on the next access.
+	mapper.sources[0], mapper.pages[0] = NameTableCHR, 6
+	mapper.sources[3], mapper.pages[3] = NameTableCartridgeRAM, 1
+	if data := ppu.PPURead(0x2005); data != 0x06 {
+		t.Errorf("$2005 = $%02X after switching to CHR page 6", data)
+	}
+	if data := ppu.PPURead(0x2C05); data != 0x22 {
+		t.Errorf("$2C05 = $%02X after switching to cartridge VRAM page 1", data)
+	}
+}
+
+// TestNameTableMapperCHRRAM Name tables in CHR RAM can be written.
+func TestNameTableMapperCHRRAM(t *testing.T) {
+	bus, mapper := newNameTableTestBus(t, 0)
+	if bus.cartridge.chrBanks != 0 {
+		t.Fatalf("got %d CHR ROM banks, expected CHR RAM", bus.cartridge.chrBanks)
+	}
+	for table := range mapper.sources {
+		mapper.sources[table], mapper.pages[table] = NameTableCHR, uint32(table)
+	}
+
+	bus.PPU.PPUWrite(0x2C00, 0x55)
+	if bus.cartridge.chrMemory[3*1024] != 0x55 {
+		t.Errorf("CHR RAM page 3 got $%02X, expected $55", bus.cartridge.chrMemory[3*1024])
+	}
+	// Pattern table sees the same memory.
+	if data := bus.PPU.PPURead(0x0C00); data != 0x55 {
+		t.Errorf("$0C00 = $%02X, expected $55", data)
+	}
+}
+
+// TestFourScreen Boards with four-screen VRAM have name tables 2 and 3 in
+// cartridge VRAM, without a NameTableMapper.
+func TestFourScreen(t *testing.T) {
+	bus, err := newTestBus(makeROM(0, 1, 1, 0x08, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ppu := bus.PPU
+
+	for table := uint16(0); table < 4; table++ {
+		ppu.PPUWrite(0x2000+table*0x400, uint8(0xA0+table))
+	}
+	for table := uint16(0); table < 4; table++ {
+		if data := ppu.PPURead(0x2000 + table*0x400); data != uint8(0xA0+table) {
+			t.Errorf("name table %d = $%02X, expected $%02X", table, data, 0xA0+table)
+		}
+	}
+	if ppu.TableName[0][0] != 0xA0 || ppu.TableName[1][0] != 0xA1 {
+		t.Errorf("name tables 0 and 1 not in CIRAM")
+	}
+	if bus.cartridge.vram[0] != 0xA2 || bus.cartridge.vram[1024] != 0xA3 {
+		t.Errorf("name tables 2 and 3 not in cartridge VRAM")
+	}
+}
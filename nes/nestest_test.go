@@ -0,0 +1,63 @@
+package nes
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+)
+
+const (
+	nestestROM = "../test/nestest.nes"
+	nestestLog = "../test/nestest.log"
+
+	// Number of instructions in the reference log.
+	nestestLines = 8991
+)
+
+// TestNestest Run nestest in automation mode from $C000 and compare the
+// trace against the reference log line by line.
+func TestNestest(t *testing.T) {
+	cart, err := NewCartridge(nestestROM)
+	if err != nil {
+		t.Fatalf("loading %s: %v", nestestROM, err)
+	}
+
+	bus := NewBus()
+	bus.InsertCartridge(cart)
+	bus.Reset()
+	bus.StepInstruction()
+	bus.CPU.PC = 0xC000
+
+	f, err := os.Open(nestestLog)
+	if err != nil {
+		t.Fatalf("opening reference log: %v", err)
+	}
+	var expected []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		expected = append(expected, strings.TrimRight(scanner.Text(), "\r "))
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("reading %s: %v", nestestLog, err)
+	}
+	if len(expected) != nestestLines {
+		t.Fatalf("%s has %d lines, expected %d", nestestLog, len(expected), nestestLines)
+	}
+
+	for i := 0; i < nestestLines; i++ {
+		line := bus.Trace()
+		if line != expected[i] {
+			t.Fatalf("trace diverges at line %d\nexpected: %s\ngot:      %s", i+1, expected[i], line)
+		}
+		if !bus.StepInstruction() {
+			t.Fatalf("CPU jammed at line %d: %s", i+1, line)
+		}
+	}
+
+	// Official and unofficial opcode results are reported in $02 and $03.
+	if official, unofficial := bus.CPURAM[0x02], bus.CPURAM[0x03]; official != 0 || unofficial != 0 {
+		t.Errorf("nestest failed: $02=%02X $03=%02X", official, unofficial)
+	}
+}
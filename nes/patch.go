@@ -0,0 +1,306 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Patch formats
+const (
+	PatchIPS = "IPS"
+	PatchUPS = "UPS"
+	PatchBPS = "BPS"
+)
+
+// Largest image a patch may make, .nes header, trainer and ROMs of maximum size.
+const maxPatchedSize = 16 + 512 + 2*maxROMSize
+
+// AppliedPatch Patch applied to a cartridge image by NewCartridge.
+type AppliedPatch struct {
+	Name   string
+	Format string
+}
+
+// ApplyPatch Apply an IPS, UPS or BPS patch to a raw .nes image, format is
+// told by the patch magic. Returns the patched image and format name.
+func ApplyPatch(rom []byte, patch []byte) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte("PATCH")):
+		out, err := applyIPS(rom, patch)
+		return out, PatchIPS, err
+	case bytes.HasPrefix(patch, []byte("UPS1")):
+		out, err := applyUPS(rom, patch)
+		return out, PatchUPS, err
+	case bytes.HasPrefix(patch, []byte("BPS1")):
+		out, err := applyBPS(rom, patch)
+		return out, PatchBPS, err
+	}
+
+	return nil, "", fmt.Errorf("%w: unknown format", ErrBadPatch)
+}
+
+// IPS
+// See http://www.smwiki.net/wiki/IPS_file_format
+func applyIPS(rom []byte, patch []byte) ([]byte, error) {
+	out := make([]byte, len(rom))
+	copy(out, rom)
+
+	pos := 5
+	for {
+		if pos+3 > len(patch) {
+			return nil, fmt.Errorf("%w: IPS ends without EOF marker", ErrBadPatch)
+		}
+		if string(patch[pos:pos+3]) == "EOF" {
+			pos += 3
+			break
+		}
+
+		offset := int(patch[pos])<<16 | int(patch[pos+1])<<8 | int(patch[pos+2])
+		pos += 3
+		if pos+2 > len(patch) {
+			return nil, fmt.Errorf("%w: IPS record cut short", ErrBadPatch)
+		}
+		size := int(binary.BigEndian.Uint16(patch[pos:]))
+		pos += 2
+
+		var data []byte
+		if size == 0 {
+			// RLE record, 16 bit count followed by the byte to repeat.
+			if pos+3 > len(patch) {
+				return nil, fmt.Errorf("%w: IPS RLE record cut short", ErrBadPatch)
+			}
+			size = int(binary.BigEndian.Uint16(patch[pos:]))
+			data = bytes.Repeat(patch[pos+2:pos+3], size)
+			pos += 3
+		} else {
+			if pos+size > len(patch) {
+				return nil, fmt.Errorf("%w: IPS record cut short", ErrBadPatch)
+			}
+			data = patch[pos : pos+size]
+			pos += size
+		}
+
+		if offset+size > len(out) {
+			out = append(out, make([]byte, offset+size-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	// Truncation extension, 24 bit size after EOF marker.
+	if pos+3 <= len(patch) {
+		size := int(patch[pos])<<16 | int(patch[pos+1])<<8 | int(patch[pos+2])
+		if size < len(out) {
+			out = out[:size]
+		}
+	}
+
+	return out, nil
+}
+
+// Reader of variable length numbers used by UPS and BPS.
+type patchReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (reader *patchReader) byte() byte {
+	if reader.pos >= len(reader.data) {
+		if reader.err == nil {
+			reader.err = fmt.Errorf("%w: unexpected end of patch", ErrBadPatch)
+		}
+		return 0
+	}
+	b := reader.data[reader.pos]
+	reader.pos++
+	return b
+}
+
+// Read a number, at most 9 bytes or 63 bits long so it always fits int64.
+// Longer numbers fail the reader and return 0.
+func (reader *patchReader) number() int {
+	data, shift := uint64(0), uint64(1)
+	for i := 0; reader.err == nil; i++ {
+		if i == 9 {
+			reader.err = fmt.Errorf("%w: number too long", ErrBadPatch)
+			return 0
+		}
+		b := reader.byte()
+		data += uint64(b&0x7F) * shift
+		if b&0x80 != 0 {
+			break
+		}
+		shift <<= 7
+		data += shift
+	}
+	if data > uint64(^uint(0)>>1) {
+		reader.err = fmt.Errorf("%w: number too large", ErrBadPatch)
+		return 0
+	}
+	return int(data)
+}
+
+// Read a size of target image, which can't be larger than maxPatchedSize.
+func (reader *patchReader) size() int {
+	size := reader.number()
+	if reader.err == nil && size > maxPatchedSize {
+		reader.err = fmt.Errorf("%w: %d bytes image is too large", ErrBadPatch, size)
+	}
+	return size
+}
+
+// Check footer CRC32 of source, target and patch itself.
+func checkPatchCRC(source []byte, target []byte, patch []byte) error {
+	footer := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(source) != binary.LittleEndian.Uint32(footer[0:]) {
+		return fmt.Errorf("%w: source CRC32 mismatch, patch is for another ROM", ErrPatchChecksum)
+	}
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return fmt.Errorf("%w: patch CRC32 mismatch", ErrPatchChecksum)
+	}
+	if target != nil && crc32.ChecksumIEEE(target) != binary.LittleEndian.Uint32(footer[4:]) {
+		return fmt.Errorf("%w: target CRC32 mismatch", ErrPatchChecksum)
+	}
+	return nil
+}
+
+// UPS
+// See http://individual.utoronto.ca/dmeunier/ups-spec.pdf
+func applyUPS(rom []byte, patch []byte) ([]byte, error) {
+	if len(patch) < 4+12 {
+		return nil, fmt.Errorf("%w: UPS too short", ErrBadPatch)
+	}
+	if err := checkPatchCRC(rom, nil, patch); err != nil {
+		return nil, err
+	}
+
+	reader := patchReader{data: patch[:len(patch)-12], pos: 4}
+	sourceSize := reader.number()
+	targetSize := reader.size()
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	if sourceSize != len(rom) {
+		return nil, fmt.Errorf("%w: UPS expects %d bytes ROM, got %d", ErrBadPatch, sourceSize, len(rom))
+	}
+
+	out := make([]byte, targetSize)
+	copy(out, rom)
+
+	offset := 0
+	for reader.err == nil && reader.pos < len(reader.data) {
+		skip := reader.number()
+		if skip > len(out)-offset {
+			return nil, fmt.Errorf("%w: UPS offset out of range", ErrBadPatch)
+		}
+		offset += skip
+		for reader.err == nil {
+			b := reader.byte()
+			if b == 0 {
+				offset++
+				break
+			}
+			if offset < len(out) {
+				out[offset] ^= b
+			}
+			offset++
+		}
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if err := checkPatchCRC(rom, out, patch); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BPS
+// See https://www.romhacking.net/documents/746/
+func applyBPS(rom []byte, patch []byte) ([]byte, error) {
+	if len(patch) < 4+12 {
+		return nil, fmt.Errorf("%w: BPS too short", ErrBadPatch)
+	}
+	if err := checkPatchCRC(rom, nil, patch); err != nil {
+		return nil, err
+	}
+
+	reader := patchReader{data: patch[:len(patch)-12], pos: 4}
+	sourceSize := reader.number()
+	targetSize := reader.size()
+	metadata := reader.number()
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	if metadata > len(reader.data)-reader.pos {
+		return nil, fmt.Errorf("%w: bad BPS header", ErrBadPatch)
+	}
+	reader.pos += metadata // Skip metadata.
+	if sourceSize != len(rom) {
+		return nil, fmt.Errorf("%w: BPS expects %d bytes ROM, got %d", ErrBadPatch, sourceSize, len(rom))
+	}
+
+	out := make([]byte, targetSize)
+	outPos, sourceRel, targetRel := 0, 0, 0
+	bad := fmt.Errorf("%w: BPS action out of range", ErrBadPatch)
+
+	for reader.err == nil && reader.pos < len(reader.data) {
+		data := reader.number()
+		length := data>>2 + 1
+		if length > len(out)-outPos {
+			return nil, bad
+		}
+
+		switch data & 0x03 {
+		case 0: // SourceRead
+			if outPos+length > len(rom) {
+				return nil, bad
+			}
+			copy(out[outPos:], rom[outPos:outPos+length])
+		case 1: // TargetRead
+			if length > len(reader.data)-reader.pos {
+				return nil, bad
+			}
+			copy(out[outPos:], reader.data[reader.pos:reader.pos+length])
+			reader.pos += length
+		case 2: // SourceCopy
+			offset := reader.number()
+			if offset&1 != 0 {
+				sourceRel -= offset >> 1
+			} else {
+				sourceRel += offset >> 1
+			}
+			if sourceRel < 0 || sourceRel+length > len(rom) {
+				return nil, bad
+			}
+			copy(out[outPos:], rom[sourceRel:sourceRel+length])
+			sourceRel += length
+		case 3: // TargetCopy, byte by byte since source and destination may overlap.
+			offset := reader.number()
+			if offset&1 != 0 {
+				targetRel -= offset >> 1
+			} else {
+				targetRel += offset >> 1
+			}
+			if targetRel < 0 || targetRel+length > len(out) {
+				return nil, bad
+			}
+			for i := 0; i < length; i++ {
+				out[outPos+i] = out[targetRel+i]
+			}
+			targetRel += length
+		}
+		outPos += length
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if err := checkPatchCRC(rom, out, patch); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// Variable length number of UPS and BPS.
+func patchNumber(data uint64) []byte {
+	var out []byte
+	for {
+		b := byte(data & 0x7F)
+		data >>= 7
+		if data == 0 {
+			return append(out, b|0x80)
+		}
+		out = append(out, b)
+		data--
+	}
+}
+
+// Append CRC32 footer of UPS and BPS.
+func patchFooter(patch []byte, source []byte, target []byte) []byte {
+	patch = concat(patch, make([]byte, 12))
+	footer := patch[len(patch)-12:]
+	binary.LittleEndian.PutUint32(footer[0:], crc32.ChecksumIEEE(source))
+	binary.LittleEndian.PutUint32(footer[4:], crc32.ChecksumIEEE(target))
+	binary.LittleEndian.PutUint32(footer[8:], crc32.ChecksumIEEE(patch[:len(patch)-4]))
+	return patch
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestApplyPatch(t *testing.T) {
+	source := []byte("0123456789")
+
+	// UPS turning source into "0X23456789!!". Skip 1, xor 1 byte, skip 7 after
+	// the terminator, xor the 2 extra bytes.
+	upsTarget := []byte("0X23456789!!")
+	ups := concat([]byte("UPS1"), patchNumber(10), patchNumber(12),
+		patchNumber(1), []byte{'1' ^ 'X', 0},
+		patchNumber(7), []byte{'!', '!', 0})
+	ups = patchFooter(ups, source, upsTarget)
+
+	// BPS making "01234XY4XY9": SourceRead 5, TargetRead 2, TargetCopy 3 from
+	// offset 4, SourceCopy 1 from offset 9.
+	bpsTarget := []byte("01234XY4XY9")
+	bps := concat([]byte("BPS1"), patchNumber(10), patchNumber(11), patchNumber(4), []byte("meta"),
+		patchNumber(4<<2|0),
+		patchNumber(1<<2|1), []byte("XY"),
+		patchNumber(2<<2|3), patchNumber(4<<1),
+		patchNumber(0<<2|2), patchNumber(9<<1))
+	bps = patchFooter(bps, source, bpsTarget)
+
+	badCRC := append([]byte(nil), ups...)
+	badCRC[5] ^= 1
+
+	tests := []struct {
+		name     string
+		patch    []byte
+		format   string
+		expected []byte
+		err      error
+	}{
+		{"unknown", []byte("NOTAPATCH"), "", nil, ErrBadPatch},
+
+		{"IPS", []byte("PATCH\x00\x00\x02\x00\x02ab\x00\x00\x0C\x00\x01zEOF"), PatchIPS, []byte("01ab456789\x00\x00z"), nil},
+		{"IPS RLE", []byte("PATCH\x00\x00\x08\x00\x00\x00\x04-EOF"), PatchIPS, []byte("01234567----"), nil},
+		{"IPS truncation", []byte("PATCH\x00\x00\x00\x00\x01aEOF\x00\x00\x04"), PatchIPS, []byte("a123"), nil},
+		{"IPS without EOF", []byte("PATCH\x00\x00\x00\x00\x01a"), PatchIPS, nil, ErrBadPatch},
+		{"IPS record cut short", []byte("PATCH\x00\x00\x00\x00\x05abEOF"), PatchIPS, nil, ErrBadPatch},
+		{"IPS RLE cut short", []byte("PATCH\x00\x00\x00\x00\x00\x00"), PatchIPS, nil, ErrBadPatch},
+
+		{"UPS", ups, PatchUPS, upsTarget, nil},
+		{"UPS source CRC", patchFooter(ups[:len(ups)-12], []byte("other"), upsTarget), PatchUPS, nil, ErrPatchChecksum},
+		{"UPS target CRC", patchFooter(ups[:len(ups)-12], source, []byte("other")), PatchUPS, nil, ErrPatchChecksum},
+		{"UPS patch CRC", badCRC, PatchUPS, nil, ErrPatchChecksum},
+		{"UPS source size", patchFooter(concat([]byte("UPS1"), patchNumber(9), patchNumber(10)), source, source), PatchUPS, nil, ErrBadPatch},
+		{"UPS huge target", patchFooter(concat([]byte("UPS1"), patchNumber(10), patchNumber(1<<40)), source, source), PatchUPS, nil, ErrBadPatch},
+		{"UPS number overflow", patchFooter(concat([]byte("UPS1"), patchNumber(10), bytes.Repeat([]byte{0x7F}, 10), []byte{0x80}), source, source), PatchUPS, nil, ErrBadPatch},
+		{"UPS offset overflow", patchFooter(concat([]byte("UPS1"), patchNumber(10), patchNumber(10), patchNumber(1<<62), []byte{1, 0}), source, source), PatchUPS, nil, ErrBadPatch},
+
+		{"BPS", bps, PatchBPS, bpsTarget, nil},
+		{"BPS target CRC", patchFooter(bps[:len(bps)-12], source, []byte("other")), PatchBPS, nil, ErrPatchChecksum},
+		{"BPS huge target", patchFooter(concat([]byte("BPS1"), patchNumber(10), patchNumber(1<<40), patchNumber(0)), source, source), PatchBPS, nil, ErrBadPatch},
+		{"BPS huge metadata", patchFooter(concat([]byte("BPS1"), patchNumber(10), patchNumber(10), patchNumber(1<<62)), source, source), PatchBPS, nil, ErrBadPatch},
+		{"BPS number overflow", patchFooter(concat([]byte("BPS1"), patchNumber(10), patchNumber(10), patchNumber(0), bytes.Repeat([]byte{0x7F}, 10), []byte{0x80}), source, source), PatchBPS, nil, ErrBadPatch},
+		{"BPS length overflow", patchFooter(concat([]byte("BPS1"), patchNumber(10), patchNumber(10), patchNumber(0), patchNumber(1<<62|1)), source, source), PatchBPS, nil, ErrBadPatch},
+		{"BPS source copy before start", patchFooter(concat([]byte("BPS1"), patchNumber(10), patchNumber(10), patchNumber(0), patchNumber(0<<2|2), patchNumber(1<<1|1)), source, source), PatchBPS, nil, ErrBadPatch},
+		{"BPS target copy past end", patchFooter(concat([]byte("BPS1"), patchNumber(10), patchNumber(10), patchNumber(0), patchNumber(0<<2|3), patchNumber(20<<1)), source, source), PatchBPS, nil, ErrBadPatch},
+	}
+
+	for _, test := range tests {
+		out, format, err := ApplyPatch(source, test.patch)
+		if format != test.format {
+			t.Errorf("%s: got format %q, expected %q", test.name, format, test.format)
+		}
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: got %v, expected %v", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !bytes.Equal(out, test.expected) {
+			t.Errorf("%s: got %q, expected %q", test.name, out, test.expected)
+		}
+	}
+}
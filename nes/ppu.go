@@ -1,10 +1,14 @@
 package nes
 
 import (
-	"fmt"
+	"image"
 	"image/color"
+)
 
-	"github.com/veandco/go-sdl2/sdl"
+// Screen size in pixels.
+const (
+	ScreenWidth  = 256
+	ScreenHeight = 240
 )
 
 // Bitmask for status register.
@@ -73,15 +77,16 @@ type PPU struct {
 
 	NMI bool
 
-	palette            [64]color.RGBA
-	screen             *sdl.Surface
-	spriteNameTable    []*sdl.Surface
-	spritePatternTable []*sdl.Surface
+	palette      [64]color.RGBA
+	frame        [ScreenWidth * ScreenHeight]uint8 // Palette index of each pixel.
+	screen       *image.RGBA
+	patternTable [2]*image.RGBA
 
 	FrameComplete bool
 
 	scanline int32 // Row on screen
 	cycle    int32 // Column on screen
+	oddFrame bool
 
 	status  uint8 // Control register
 	mask    uint8 // Mask register
@@ -112,41 +117,17 @@ type PPU struct {
 
 // ConnectPPU Initialize a PPU and connect it to the bus.
 func ConnectPPU(bus *Bus) *PPU {
-	var err error
 	ppu := PPU{}
 
-	// Initialize PPU spritesheet
-	// For NES screen, there's 341 cycles and 261 scanlines for each screen,
-	// but NES is only generating a frame with 256 cycles and 240 scanlines.
-	// So I doubled the frame size in both width and height so that the
-	// screen won't overflow.
-	ppu.screen, err = sdl.CreateRGBSurfaceWithFormat(0, 256, 240, 8, sdl.PIXELFORMAT_RGB888)
-	if err != nil {
-		fmt.Printf("Failed to create sprite: %s\n", err)
-		panic(err)
-	}
+	// Initialize PPU screen, it's converted from frame on demand.
+	ppu.screen = image.NewRGBA(image.Rect(0, 0, ScreenWidth, ScreenHeight))
 
 	// Initialize PPU palette
 	ppu.initializePalette()
 
-	// Initialize PPU sprite name table
-	ppu.spriteNameTable = make([]*sdl.Surface, 2)
-	for i := range ppu.spriteNameTable {
-		ppu.spriteNameTable[i], err = sdl.CreateRGBSurfaceWithFormat(0, 256, 240, 8, sdl.PIXELFORMAT_RGB888)
-		if err != nil {
-			fmt.Printf("Failed to create sprite name table %d: %s\n", i, err)
-			panic(err)
-		}
-	}
-
-	// Initialize PPU sprite pattern table
-	ppu.spritePatternTable = make([]*sdl.Surface, 2)
-	for i := range ppu.spritePatternTable {
-		ppu.spritePatternTable[i], err = sdl.CreateRGBSurfaceWithFormat(0, 256*2, 240*2, 8, sdl.PIXELFORMAT_RGB888)
-		if err != nil {
-			fmt.Printf("Failed to create pattern name table %d: %s\n", i, err)
-			panic(err)
-		}
+	// Initialize PPU pattern table, 16x16 tiles of 8x8 pixels.
+	for i := range ppu.patternTable {
+		ppu.patternTable[i] = image.NewRGBA(image.Rect(0, 0, 128, 128))
 	}
 
 	ppu.NMI = false
@@ -210,11 +191,24 @@ func (ppu *PPU) CPURead(addr uint16, readOnly ...bool) uint8 {
 		bReadOnly = readOnly[0]
 	}
 
-	// Remember to remove this line when readonly logic is implemented.
-	_ = bReadOnly
-
 	var data uint8 = 0x00
 
+	// Peek without side effects, for debuggers and tracers.
+	if bReadOnly {
+		switch addr {
+		case 0x0002: // Status
+			data = (ppu.status & 0xE0) | (ppu.ppuDataBuffer & 0x1F)
+		case 0x0004: // OAM data
+			data = ppu.OAM[ppu.oamAddr]
+		case 0x0007: // PPU data
+			data = ppu.ppuDataBuffer
+			if ppu.vramAddr >= 0x3F00 {
+				data = ppu.PPURead(ppu.vramAddr)
+			}
+		}
+		return data
+	}
+
 	switch addr {
 	case 0x0000: // Control
 
@@ -234,6 +228,7 @@ func (ppu *PPU) CPURead(addr uint16, readOnly ...bool) uint8 {
 
 	case 0x0007: // PPU data:
 		data = ppu.ppuDataBuffer
+		ppu.cartridge.PPUAddress(ppu.vramAddr)
 		ppu.ppuDataBuffer = ppu.PPURead(ppu.vramAddr)
 
 		// If CPU is reading address above 0x3F00, we need to instantly return its value
@@ -252,6 +247,16 @@ func (ppu *PPU) CPURead(addr uint16, readOnly ...bool) uint8 {
 	return data
 }
 
+// Write a byte to OAM at current OAM address and advance it.
+func (ppu *PPU) writeOAM(data uint8) {
+	// Bits 2-4 of sprite attributes don't exist and read back as zero.
+	if ppu.oamAddr&0x03 == 0x02 {
+		data &= 0xE3
+	}
+	ppu.OAM[ppu.oamAddr] = data
+	ppu.oamAddr++
+}
+
 // CPUWrite CPU write to PPU.
 func (ppu *PPU) CPUWrite(addr uint16, data uint8) {
 	switch addr {
@@ -265,7 +270,7 @@ func (ppu *PPU) CPUWrite(addr uint16, data uint8) {
 	case 0x0003: // OAM address
 		ppu.oamAddr = data
 	case 0x0004: // OAM data
-		ppu.OAM[ppu.oamAddr] = data
+		ppu.writeOAM(data)
 	case 0x0005: // Scroll
 		if ppu.addressLatch == 0 {
 			ppu.fineX = data & 0x07
@@ -285,8 +290,10 @@ func (ppu *PPU) CPUWrite(addr uint16, data uint8) {
 			ppu.tramAddr = (ppu.tramAddr & 0xFF00) | uint16(data)
 			ppu.vramAddr = ppu.tramAddr
 			ppu.addressLatch = 0
+			ppu.cartridge.PPUAddress(ppu.vramAddr)
 		}
 	case 0x0007: // PPU data
+		ppu.cartridge.PPUAddress(ppu.vramAddr)
 		ppu.PPUWrite(ppu.vramAddr, data)
 		if ppu.getFlag(&ppu.control, controlIncrementMode) != 0 {
 			ppu.vramAddr += 32
@@ -316,37 +323,8 @@ func (ppu *PPU) PPURead(addr uint16, readOnly ...bool) uint8 {
 	} else if addr >= 0x0000 && addr <= 0x1FFF { // Pattern memory
 		data = ppu.tablePattern[(addr&0x1000)>>12][addr&0x0FFF]
 	} else if addr >= 0x2000 && addr <= 0x3EFF { // Name Table memory
-		addr &= 0x0FFF
-
-		if ppu.cartridge.mirror == mirrorVertical {
-			// Vertical
-			if addr >= 0x0000 && addr <= 0x03FF {
-				data = ppu.TableName[0][addr&0x03FF]
-			}
-			if addr >= 0x0400 && addr <= 0x07FF {
-				data = ppu.TableName[1][addr&0x03FF]
-			}
-			if addr >= 0x0800 && addr <= 0x0BFF {
-				data = ppu.TableName[0][addr&0x03FF]
-			}
-			if addr >= 0x0C00 && addr <= 0x0FFF {
-				data = ppu.TableName[1][addr&0x03FF]
-			}
-		} else if ppu.cartridge.mirror == mirrorHorizontal {
-			// Horizontal
-			if addr >= 0x0000 && addr <= 0x03FF {
-				data = ppu.TableName[0][addr&0x03FF]
-			}
-			if addr >= 0x0400 && addr <= 0x07FF {
-				data = ppu.TableName[0][addr&0x03FF]
-			}
-			if addr >= 0x0800 && addr <= 0x0BFF {
-				data = ppu.TableName[1][addr&0x03FF]
-			}
-			if addr >= 0x0C00 && addr <= 0x0FFF {
-				data = ppu.TableName[1][addr&0x03FF]
-			}
-		}
+		table, _ := ppu.cartridge.NameTable((addr>>10)&0x03, &ppu.TableName)
+		data = table[addr&0x03FF]
 	} else if addr >= 0x3F00 && addr <= 0x3FFF { // Palette Memory
 		addr &= 0x001F
 		if addr == 0x0010 {
@@ -381,36 +359,8 @@ func (ppu *PPU) PPUWrite(addr uint16, data uint8) {
 	} else if addr >= 0x0000 && addr <= 0x1FFF { // Pattern memory
 		ppu.tablePattern[(addr&0x1000)>>12][addr&0x0FFF] = data
 	} else if addr >= 0x2000 && addr <= 0x3EFF { // Name Table memory
-		addr &= 0x0FFF
-
-		if ppu.cartridge.mirror == mirrorVertical {
-			// Vertical
-			if addr >= 0x0000 && addr <= 0x03FF {
-				ppu.TableName[0][addr&0x03FF] = data
-			}
-			if addr >= 0x0400 && addr <= 0x07FF {
-				ppu.TableName[1][addr&0x03FF] = data
-			}
-			if addr >= 0x0800 && addr <= 0x0BFF {
-				ppu.TableName[0][addr&0x03FF] = data
-			}
-			if addr >= 0x0C00 && addr <= 0x0FFF {
-				ppu.TableName[1][addr&0x03FF] = data
-			}
-		} else if ppu.cartridge.mirror == mirrorHorizontal {
-			// Horizontal
-			if addr >= 0x0000 && addr <= 0x03FF {
-				ppu.TableName[0][addr&0x03FF] = data
-			}
-			if addr >= 0x0400 && addr <= 0x07FF {
-				ppu.TableName[0][addr&0x03FF] = data
-			}
-			if addr >= 0x0800 && addr <= 0x0BFF {
-				ppu.TableName[1][addr&0x03FF] = data
-			}
-			if addr >= 0x0C00 && addr <= 0x0FFF {
-				ppu.TableName[1][addr&0x03FF] = data
-			}
+		if table, writable := ppu.cartridge.NameTable((addr>>10)&0x03, &ppu.TableName); writable {
+			table[addr&0x03FF] = data
 		}
 	} else if addr >= 0x3F00 && addr <= 0x3FFF { // Palette Memory
 		addr &= 0x001F
@@ -430,6 +380,87 @@ func (ppu *PPU) PPUWrite(addr uint16, data uint8) {
 	}
 }
 
+// Read made by rendering pipeline, mappers may watch its address while rendering is enabled.
+func (ppu *PPU) renderFetch(addr uint16) uint8 {
+	if (ppu.getFlag(&ppu.mask, maskRenderBackground) != 0) ||
+		(ppu.getFlag(&ppu.mask, maskRenderSprites) != 0) {
+		ppu.cartridge.PPUAddress(addr)
+	}
+	return ppu.PPURead(addr)
+}
+
+// Pattern address of the low byte of sprite slot i on current scanline, tile
+// $FF for empty slots.
+func (ppu *PPU) spritePatternAddr(i uint8) uint16 {
+	if i >= ppu.spriteCount {
+		if ppu.getFlag(&ppu.control, controlSpriteSize) == 0 {
+			return (uint16(ppu.getFlag(&ppu.control, controlPatternSprite)) << 12) | (0xFF << 4)
+		}
+		return 0x1000 | (0xFE << 4)
+	}
+
+	var addr uint16
+	if ppu.getFlag(&ppu.control, controlSpriteSize) == 0 {
+		// 8x8
+		if (ppu.sprite[i*4+entryAttribute] & 0x80) == 0 {
+			// Normal
+			addr =
+				(uint16(ppu.getFlag(&ppu.control, controlPatternSprite)) << 12) |
+					(uint16(ppu.sprite[i*4+entryID]) << 4) |
+					(uint16(ppu.scanline) - uint16(ppu.sprite[i*4+entryY]))
+
+		} else {
+			// Flipped
+			addr =
+				(uint16(ppu.getFlag(&ppu.control, controlPatternSprite)) << 12) |
+					((uint16(ppu.sprite[i*4+entryID]) + 1) << 4) |
+					(7 - uint16(ppu.scanline) - uint16(ppu.sprite[i*4+entryY]))
+		}
+	} else {
+		// 8x16
+		if (ppu.sprite[i*4+entryAttribute] & 0x80) == 0 {
+			// Normal
+			if (uint8(ppu.scanline) - ppu.sprite[i*4+entryY]) < 8 {
+				// Top half tile
+				addr =
+					(uint16(ppu.sprite[i*4+entryID]&0x01) << 12) |
+						(uint16(ppu.sprite[i*4+entryID]) & 0xFE << 4) |
+						(uint16(uint16(ppu.scanline)-uint16(ppu.sprite[i*4+entryY])) & 0x07)
+			} else {
+				// Bottom half tile
+				addr =
+					(uint16(ppu.sprite[i*4+entryID]&0x01) << 12) |
+						((uint16(ppu.sprite[i*4+entryID])&0xFE + 1) << 4) |
+						(uint16(uint16(ppu.scanline)-uint16(ppu.sprite[i*4+entryY])) & 0x07)
+			}
+		} else {
+			// Flipped
+			if (uint8(ppu.scanline) - ppu.sprite[i*4+0]) < 8 {
+				// Top half tile
+				addr =
+					(uint16(ppu.sprite[i*4+entryID]&0x01) << 12) |
+						((uint16(ppu.sprite[i*4+entryID]&0xFE) + 1) << 4) |
+						((7 - uint16(ppu.scanline) - uint16(ppu.sprite[i*4+entryY])) & 0x07)
+			} else {
+				// Bottom half tile
+				addr =
+					(uint16(ppu.sprite[i*4+entryID]&0x01) << 12) |
+						(uint16(ppu.sprite[i*4+entryID]&0xFE) << 4) |
+						((7 - uint16(ppu.scanline) - uint16(ppu.sprite[i*4+entryY])) & 0x07)
+			}
+		}
+	}
+	return addr
+}
+
+// Mirror bits of a byte, for horizontally flipped sprites.
+func flipByte(b uint8) uint8 {
+	b = (b&0xF0)>>4 | (b&0x0F)<<4
+	b = (b&0xCC)>>2 | (b&0x33)<<2
+	b = (b&0xAA)>>1 | (b&0x55)<<1
+	return b
+}
+
 // ConnectCartridge Connect cartridge to PPU.
 func (ppu *PPU) ConnectCartridge(cart *Cartridge) {
 	ppu.cartridge = cart
@@ -523,10 +554,6 @@ func (ppu *PPU) Clock() {
 	}
 
 	if ppu.scanline >= -1 && ppu.scanline < 240 {
-		if ppu.scanline == 0 && ppu.cycle == 0 {
-			ppu.cycle = 1
-		}
-
 		if ppu.scanline == -1 && ppu.cycle == 1 {
 			ppu.setFlag(&ppu.status, statusVerticalBlank, false)
 			ppu.setFlag(&ppu.status, statusSpriteZeroHit, false)
@@ -545,14 +572,14 @@ func (ppu *PPU) Clock() {
 			case 0:
 				// Fetch next name table.
 				loadBackgroundShifters()
-				ppu.nextTileID = ppu.PPURead(0x2000 | (ppu.vramAddr & 0x0FFF))
+				ppu.nextTileID = ppu.renderFetch(0x2000 | (ppu.vramAddr & 0x0FFF))
 			case 2:
 				// Fetch next attribute table.
 				addr := (0x23C0 | (ppu.getLoppyRegister(&ppu.vramAddr, loppyNameTableY) << 11) |
 					(ppu.getLoppyRegister(&ppu.vramAddr, loppyNameTableX) << 10) |
 					((ppu.getLoppyRegister(&ppu.vramAddr, loppyCoarseY) >> 2) << 3) |
 					(ppu.getLoppyRegister(&ppu.vramAddr, loppyCoarseX) >> 2))
-				ppu.nextTileAttr = ppu.PPURead(addr)
+				ppu.nextTileAttr = ppu.renderFetch(addr)
 				if ppu.getLoppyRegister(&ppu.vramAddr, loppyCoarseY)&0x02 != 0 {
 					ppu.nextTileAttr >>= 4
 				}
@@ -562,12 +589,12 @@ func (ppu *PPU) Clock() {
 				ppu.nextTileAttr &= 0x03
 			case 4:
 				// Fetch LSB
-				ppu.nextTileLSB = ppu.PPURead((uint16(ppu.getFlag(&ppu.control, controlPatternBackground)) << 12) +
+				ppu.nextTileLSB = ppu.renderFetch((uint16(ppu.getFlag(&ppu.control, controlPatternBackground)) << 12) +
 					(uint16(ppu.nextTileID) << 4) +
 					ppu.getLoppyRegister(&ppu.vramAddr, loppyFineY) + 0)
 			case 6:
 				// Fetch MSB
-				ppu.nextTileMSB = ppu.PPURead((uint16(ppu.getFlag(&ppu.control, controlPatternBackground)) << 12) +
+				ppu.nextTileMSB = ppu.renderFetch((uint16(ppu.getFlag(&ppu.control, controlPatternBackground)) << 12) +
 					(uint16(ppu.nextTileID) << 4) +
 					ppu.getLoppyRegister(&ppu.vramAddr, loppyFineY) + 8)
 			case 7:
@@ -589,7 +616,7 @@ func (ppu *PPU) Clock() {
 		}
 
 		if ppu.cycle == 338 || ppu.cycle == 340 {
-			ppu.nextTileID = ppu.PPURead(0x2000 | (ppu.vramAddr & 0x0FFF))
+			ppu.nextTileID = ppu.renderFetch(0x2000 | (ppu.vramAddr & 0x0FFF))
 		}
 
 		// Sprite Evaluation
@@ -631,81 +658,27 @@ func (ppu *PPU) Clock() {
 			}
 		}
 
-		if ppu.cycle == 340 {
-			for i := uint8(0); i < ppu.spriteCount; i++ {
-				var spritePatternBitsLo, spritePatternBitsHi uint8
-				var spritePatternAddrLo, spritePatternAddrHi uint16
-
-				if ppu.getFlag(&ppu.control, controlSpriteSize) == 0 {
-					// 8x8
-					if (ppu.sprite[i*4+entryAttribute] & 0x80) == 0 {
-						// Normal
-						spritePatternAddrLo =
-							(uint16(ppu.getFlag(&ppu.control, controlPatternSprite)) << 12) |
-								(uint16(ppu.sprite[i*4+entryID]) << 4) |
-								(uint16(ppu.scanline) - uint16(ppu.sprite[i*4+entryY]))
-
-					} else {
-						// Flipped
-						spritePatternAddrLo =
-							(uint16(ppu.getFlag(&ppu.control, controlPatternSprite)) << 12) |
-								((uint16(ppu.sprite[i*4+entryID]) + 1) << 4) |
-								(7 - uint16(ppu.scanline) - uint16(ppu.sprite[i*4+entryY]))
-					}
+		// Sprite pattern fetches, slot i takes dots 257+8i to 264+8i with
+		// low and high pattern byte read on its fifth and seventh dot.
+		if ppu.cycle >= 257 && ppu.cycle <= 320 {
+			i := uint8((ppu.cycle - 257) / 8)
+			switch (ppu.cycle - 257) % 8 {
+			case 4:
+				ppu.spriteShifterPatternLo[i] = ppu.renderFetch(ppu.spritePatternAddr(i))
+			case 6:
+				hi := ppu.renderFetch(ppu.spritePatternAddr(i) + 8)
+				if i >= ppu.spriteCount {
+					// Empty slots still fetch tile $FF, mappers counting
+					// scanlines by A12 rely on these fetches.
+					ppu.spriteShifterPatternLo[i] = 0
 				} else {
-					// 8x16
-					if (ppu.sprite[i*4+entryAttribute] & 0x80) == 0 {
-						// Normal
-						if (uint8(ppu.scanline) - ppu.sprite[i*4+entryY]) < 8 {
-							// Top half tile
-							spritePatternAddrLo =
-								(uint16(ppu.sprite[i*4+entryID]&0x01) << 12) |
-									(uint16(ppu.sprite[i*4+entryID]) & 0xFE << 4) |
-									(uint16(uint16(ppu.scanline)-uint16(ppu.sprite[i*4+entryY])) & 0x07)
-						} else {
-							// Bottom half tile
-							spritePatternAddrLo =
-								(uint16(ppu.sprite[i*4+entryID]&0x01) << 12) |
-									((uint16(ppu.sprite[i*4+entryID])&0xFE + 1) << 4) |
-									(uint16(uint16(ppu.scanline)-uint16(ppu.sprite[i*4+entryY])) & 0x07)
-						}
-					} else {
-						// Flipped
-						if (uint8(ppu.scanline) - ppu.sprite[i*4+0]) < 8 {
-							// Top half tile
-							spritePatternAddrLo =
-								(uint16(ppu.sprite[i*4+entryID]&0x01) << 12) |
-									((uint16(ppu.sprite[i*4+entryID]&0xFE) + 1) << 4) |
-									((7 - uint16(ppu.scanline) - uint16(ppu.sprite[i*4+entryY])) & 0x07)
-						} else {
-							// Bottom half tile
-							spritePatternAddrLo =
-								(uint16(ppu.sprite[i*4+entryID]&0x01) << 12) |
-									(uint16(ppu.sprite[i*4+entryID]&0xFE) << 4) |
-									((7 - uint16(ppu.scanline) - uint16(ppu.sprite[i*4+entryY])) & 0x07)
-						}
-					}
-				}
-
-				spritePatternAddrHi = spritePatternAddrLo + 8
-
-				spritePatternBitsLo = ppu.PPURead(spritePatternAddrLo)
-				spritePatternBitsHi = ppu.PPURead(spritePatternAddrHi)
-
-				if ppu.sprite[i*4+entryAttribute]&0x40 != 0 {
-					var flipByte func(b uint8) uint8 = func(b uint8) uint8 {
-						b = (b&0xF0)>>4 | (b&0x0F)<<4
-						b = (b&0xCC)>>2 | (b&0x33)<<2
-						b = (b&0xAA)>>1 | (b&0x55)<<1
-						return b
+					lo := ppu.spriteShifterPatternLo[i]
+					if ppu.sprite[i*4+entryAttribute]&0x40 != 0 {
+						lo, hi = flipByte(lo), flipByte(hi)
 					}
-
-					spritePatternBitsLo = flipByte(spritePatternBitsLo)
-					spritePatternBitsHi = flipByte(spritePatternBitsHi)
+					ppu.spriteShifterPatternLo[i] = lo
+					ppu.spriteShifterPatternHi[i] = hi
 				}
-
-				ppu.spriteShifterPatternLo[i] = spritePatternBitsLo
-				ppu.spriteShifterPatternHi[i] = spritePatternBitsHi
 			}
 		}
 	}
@@ -825,9 +798,9 @@ func (ppu *PPU) Clock() {
 		}
 	}
 
-	if ppu.cycle >= 0 && ppu.cycle < 256 && ppu.scanline >= 0 && ppu.scanline < 240 {
-		ppu.screen.Set(int(ppu.cycle-1), int(ppu.scanline),
-			ppu.GetColorFromPaletteRAM(palette, pixel))
+	if ppu.cycle >= 1 && ppu.cycle <= ScreenWidth && ppu.scanline >= 0 && ppu.scanline < ScreenHeight {
+		ppu.frame[int(ppu.scanline)*ScreenWidth+int(ppu.cycle-1)] =
+			ppu.PPURead(0x3F00+(uint16(palette)<<2)+uint16(pixel)) & 0x3F
 	}
 
 	// Draw old-fashioned static noise.
@@ -846,26 +819,44 @@ func (ppu *PPU) Clock() {
 
 	// Advance renderer, it's relentless and it never stops.
 	ppu.cycle++
+
+	// Odd frames skip last dot of pre-render scanline while rendering.
+	rendering := ppu.getFlag(&ppu.mask, maskRenderBackground) != 0 || ppu.getFlag(&ppu.mask, maskRenderSprites) != 0
+	if ppu.scanline == -1 && ppu.cycle == 340 && ppu.oddFrame && rendering {
+		ppu.cycle = 341
+	}
+
 	if ppu.cycle >= 341 {
 		ppu.cycle = 0
 		ppu.scanline++
 		if ppu.scanline >= 261 {
 			ppu.scanline = -1
 			ppu.FrameComplete = true
+			ppu.oddFrame = !ppu.oddFrame
 		}
 	}
 }
 
 // Debug utilities
 
-// GetScreen Return PPU rendered screen.
-func (ppu *PPU) GetScreen() *sdl.Surface {
+// GetFrame Return PPU rendered frame as 256x240 palette indices.
+func (ppu *PPU) GetFrame() []uint8 {
+	return ppu.frame[:]
+}
+
+// GetScreen Return PPU rendered screen in RGBA. The image is reused by later calls.
+func (ppu *PPU) GetScreen() *image.RGBA {
+	for i, index := range ppu.frame {
+		c := ppu.palette[index]
+		pix := ppu.screen.Pix[i*4 : i*4+4]
+		pix[0], pix[1], pix[2], pix[3] = c.R, c.G, c.B, c.A
+	}
 	return ppu.screen
 }
 
-// GetNameTable Return PPU internal name table.
-func (ppu *PPU) GetNameTable(i uint8) *sdl.Surface {
-	return ppu.spriteNameTable[i]
+// GetPaletteColor Get RGBA color of a palette index.
+func (ppu *PPU) GetPaletteColor(index uint8) color.RGBA {
+	return ppu.palette[index&0x3F]
 }
 
 // GetColorFromPaletteRAM Get a color from PPU internal palette RAM.
@@ -874,7 +865,7 @@ func (ppu *PPU) GetColorFromPaletteRAM(palette uint8, pixel uint8) color.RGBA {
 }
 
 // GetPatternTable Get PPU internal pattern table.
-func (ppu *PPU) GetPatternTable(i uint8, palette uint8) *sdl.Surface {
+func (ppu *PPU) GetPatternTable(i uint8, palette uint8) *image.RGBA {
 	for tileY := 0; tileY < 16; tileY++ {
 		for tileX := 0; tileX < 16; tileX++ {
 			var offset uint16 = uint16(tileY*256 + tileX*16) // Byte offset
@@ -888,7 +879,7 @@ func (ppu *PPU) GetPatternTable(i uint8, palette uint8) *sdl.Surface {
 					tileLSB >>= 1
 					tileMSB >>= 1
 
-					ppu.spritePatternTable[i].Set(
+					ppu.patternTable[i].SetRGBA(
 						tileX*8+(7-col),
 						tileY*8+row,
 						ppu.GetColorFromPaletteRAM(palette, pixel),
@@ -898,5 +889,5 @@ func (ppu *PPU) GetPatternTable(i uint8, palette uint8) *sdl.Surface {
 		}
 	}
 
-	return ppu.spritePatternTable[i]
+	return ppu.patternTable[i]
 }
@@ -0,0 +1,208 @@
+package nes
+
+import (
+	"bytes"
+	"compress/flate"
+	"io/ioutil"
+)
+
+// Rewinder Keeps recent history of a bus to run time backwards.
+//
+// A snapshot is taken every interval frames. Latest snapshot is kept whole,
+// older ones only as the flate-compressed XOR against their successor, which
+// is mostly zeros since little changes between frames. Oldest snapshots are
+// dropped once depth or the memory budget is exceeded. Controller input of
+// every frame is kept too, frames between snapshots are replayed from the
+// snapshot before them, so rewinding goes back one frame at a time.
+type Rewinder struct {
+	bus *Bus
+
+	interval int // Frames between snapshots.
+	depth    int // Max snapshots kept.
+	budget   int // Max bytes kept.
+
+	current []byte     // Latest snapshot.
+	inputs  [][2]uint8 // Input of each frame since latest snapshot.
+	scratch bytes.Buffer
+
+	// Ring buffer of older snapshots, oldest at head.
+	history []rewindSnapshot
+	head    int
+	count   int
+	size    int // Bytes used by deltas.
+
+	compressor *flate.Writer
+}
+
+// Snapshot in rewind history, and input of the frames that followed it.
+type rewindSnapshot struct {
+	delta  []byte // XOR against the next snapshot, compressed.
+	inputs [][2]uint8
+}
+
+// NewRewinder Create a rewinder for bus snapshotting every interval frames,
+// keeping at most depth snapshots in budget bytes, or any size if budget is 0.
+func NewRewinder(bus *Bus, interval int, depth int, budget int) *Rewinder {
+	if interval < 1 {
+		interval = 1
+	}
+	if depth < 1 {
+		depth = 1
+	}
+
+	rewinder := Rewinder{}
+	rewinder.bus = bus
+	rewinder.interval = interval
+	rewinder.depth = depth
+	rewinder.budget = budget
+	rewinder.history = make([]rewindSnapshot, depth-1)
+	rewinder.compressor, _ = flate.NewWriter(ioutil.Discard, flate.BestSpeed)
+	return &rewinder
+}
+
+// Frame Tell rewinder a frame has been emulated, a snapshot is taken when due.
+func (rewinder *Rewinder) Frame() error {
+	if rewinder.current != nil {
+		input := [2]uint8{rewinder.bus.Controller[0], rewinder.bus.Controller[1]}
+		rewinder.inputs = append(rewinder.inputs, input)
+		if len(rewinder.inputs) < rewinder.interval {
+			return nil
+		}
+	}
+	return rewinder.capture()
+}
+
+func (rewinder *Rewinder) capture() error {
+	rewinder.scratch.Reset()
+	if err := rewinder.bus.SaveState(&rewinder.scratch); err != nil {
+		return err
+	}
+	snapshot := append([]byte(nil), rewinder.scratch.Bytes()...)
+
+	if rewinder.current != nil {
+		delta, err := rewinder.compress(xorBytes(snapshot, rewinder.current))
+		if err != nil {
+			return err
+		}
+		rewinder.push(rewindSnapshot{delta, rewinder.inputs})
+	}
+	rewinder.current = snapshot
+	rewinder.inputs = nil
+
+	// Latest snapshot counts against the budget too.
+	for rewinder.budget > 0 && rewinder.count > 0 && rewinder.size+len(rewinder.current) > rewinder.budget {
+		rewinder.dropOldest()
+	}
+	return nil
+}
+
+// Rewind Step back one frame. Returns false if there's no history left.
+func (rewinder *Rewinder) Rewind() (bool, error) {
+	if rewinder.current == nil {
+		return false, nil
+	}
+
+	// Frame before latest snapshot is somewhere after the previous one.
+	if len(rewinder.inputs) == 0 {
+		if rewinder.count == 0 {
+			return false, nil
+		}
+		previous := rewinder.pop()
+		delta, err := rewinder.decompress(previous.delta)
+		if err != nil {
+			return false, err
+		}
+		rewinder.current = xorBytes(rewinder.current, delta)
+		rewinder.inputs = previous.inputs
+	}
+
+	rewinder.inputs = rewinder.inputs[:len(rewinder.inputs)-1]
+	if err := rewinder.bus.LoadState(bytes.NewReader(rewinder.current)); err != nil {
+		return false, err
+	}
+	for _, input := range rewinder.inputs {
+		rewinder.bus.Controller[0], rewinder.bus.Controller[1] = input[0], input[1]
+		rewinder.bus.RunFrame()
+	}
+	// Replayed audio was heard already.
+	rewinder.bus.APU.samples = rewinder.bus.APU.samples[:0]
+	return true, nil
+}
+
+// Reset Forget all history.
+func (rewinder *Rewinder) Reset() {
+	for rewinder.count > 0 {
+		rewinder.dropOldest()
+	}
+	rewinder.current = nil
+	rewinder.inputs = nil
+}
+
+// Len Number of frames that can be stepped back.
+func (rewinder *Rewinder) Len() int {
+	frames := len(rewinder.inputs)
+	for i := 0; i < rewinder.count; i++ {
+		frames += len(rewinder.history[(rewinder.head+i)%len(rewinder.history)].inputs)
+	}
+	return frames
+}
+
+// Size Bytes of memory used by history.
+func (rewinder *Rewinder) Size() int {
+	return rewinder.size + len(rewinder.current)
+}
+
+func (rewinder *Rewinder) push(snapshot rewindSnapshot) {
+	if len(rewinder.history) == 0 {
+		return
+	}
+	if rewinder.count == len(rewinder.history) {
+		rewinder.dropOldest()
+	}
+	rewinder.history[(rewinder.head+rewinder.count)%len(rewinder.history)] = snapshot
+	rewinder.count++
+	rewinder.size += len(snapshot.delta)
+}
+
+func (rewinder *Rewinder) pop() rewindSnapshot {
+	rewinder.count--
+	i := (rewinder.head + rewinder.count) % len(rewinder.history)
+	snapshot := rewinder.history[i]
+	rewinder.history[i] = rewindSnapshot{}
+	rewinder.size -= len(snapshot.delta)
+	return snapshot
+}
+
+func (rewinder *Rewinder) dropOldest() {
+	rewinder.size -= len(rewinder.history[rewinder.head].delta)
+	rewinder.history[rewinder.head] = rewindSnapshot{}
+	rewinder.head = (rewinder.head + 1) % len(rewinder.history)
+	rewinder.count--
+}
+
+func (rewinder *Rewinder) compress(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	rewinder.compressor.Reset(&out)
+	if _, err := rewinder.compressor.Write(data); err != nil {
+		return nil, err
+	}
+	if err := rewinder.compressor.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (rewinder *Rewinder) decompress(data []byte) ([]byte, error) {
+	return ioutil.ReadAll(flate.NewReader(bytes.NewReader(data)))
+}
+
+// XOR target against base, result has the length of target. Applying it to
+// base again gives target back.
+func xorBytes(base []byte, target []byte) []byte {
+	out := make([]byte, len(target))
+	copy(out, target)
+	for i := 0; i < len(out) && i < len(base); i++ {
+		out[i] ^= base[i]
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestRewind Rewinding steps back one frame at a time through the same states
+// the machine went through, however history is kept.
+func TestRewind(t *testing.T) {
+	code := []byte{
+		0xA9, 0x01, 0x8D, 0x16, 0x40, // LDA #$01, STA $4016
+		0xA9, 0x00, 0x8D, 0x16, 0x40, // LDA #$00, STA $4016
+		0xAD, 0x16, 0x40, 0x29, 0x01, // LDA $4016, AND #$01
+		0x18, 0x65, 0x10, 0x85, 0x10, // CLC, ADC $10, STA $10
+		0xE6, 0x11, // INC $11
+		0x4C, 0x00, 0x80, // JMP $8000
+	}
+	const frames = 40
+
+	tests := []struct {
+		name     string
+		interval int
+		depth    int
+		budget   int // Negative for a few deltas.
+		kept     int // Frames that can be stepped back, 0 for all.
+	}{
+		{"every frame", 1, 100, 0, 0},
+		{"every 3 frames", 3, 100, 0, 0},
+		{"ring buffer", 4, 5, 0, 4*4 + 3},
+		{"budget", 2, 100, -1, 0},
+	}
+
+	for _, test := range tests {
+		bus, err := newTestBus(makeROM(0, 1, 1, 0, code))
+		if err != nil {
+			t.Fatal(err)
+		}
+		budget := test.budget
+		if budget < 0 {
+			// Room for the latest snapshot and a few deltas.
+			var state bytes.Buffer
+			bus.SaveState(&state)
+			budget = state.Len() + 1024
+		}
+		rewinder := NewRewinder(bus, test.interval, test.depth, budget)
+
+		var states [][]byte
+		for frame := 0; frame < frames; frame++ {
+			bus.Controller[0] = uint8(frame*7) & 0x80
+			bus.RunFrame()
+			if err := rewinder.Frame(); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			states = append(states, saveStateBytes(t, bus))
+		}
+
+		kept := test.kept
+		if budget > 0 {
+			if rewinder.Size() > budget {
+				t.Errorf("%s: history uses %d bytes of %d", test.name, rewinder.Size(), budget)
+			}
+			kept = rewinder.Len()
+			if kept <= test.interval || kept >= frames-1 {
+				t.Errorf("%s: %d frames kept, expected some dropped to keep the budget", test.name, kept)
+			}
+		} else if kept == 0 {
+			kept = frames - 1
+		}
+		if rewinder.Len() != kept {
+			t.Errorf("%s: %d frames kept, expected %d", test.name, rewinder.Len(), kept)
+		}
+
+		for step := 1; step <= kept; step++ {
+			ok, err := rewinder.Rewind()
+			if !ok || err != nil {
+				t.Fatalf("%s: rewind %d failed: %t %v", test.name, step, ok, err)
+			}
+			if !bytes.Equal(saveStateBytes(t, bus), states[frames-1-step]) {
+				t.Fatalf("%s: rewind %d isn't the state of frame %d", test.name, step, frames-1-step)
+			}
+		}
+		if ok, _ := rewinder.Rewind(); ok {
+			t.Errorf("%s: rewound past the oldest frame", test.name)
+		}
+
+		// Running again after rewinding records new history.
+		bus.RunFrame()
+		rewinder.Frame()
+		if ok, err := rewinder.Rewind(); !ok || err != nil {
+			t.Fatalf("%s: rewind after running again failed: %t %v", test.name, ok, err)
+		}
+		if !bytes.Equal(saveStateBytes(t, bus), states[frames-1-kept]) {
+			t.Errorf("%s: rewind after running again isn't the oldest state", test.name)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package nes
+
+// Build an iNES image for tests. code is placed at the start of PRG ROM,
+// where the reset vector points at $8000, NMI and IRQ return right away from
+// $FFF0. Flags are header byte 6 without the mapper nibble.
+func makeROM(mapper uint8, prgBanks uint8, chrBanks uint8, flags uint8, code []byte) []byte {
+	header := []byte{'N', 'E', 'S', 0x1A, prgBanks, chrBanks, mapper<<4 | flags&0x0F, mapper & 0xF0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	prg := make([]byte, int(prgBanks)*16384)
+	for i := range prg {
+		prg[i] = 0xEA // NOP
+	}
+	copy(prg, code)
+	end := len(prg)
+	prg[end-0x10] = 0x40 // RTI
+	copy(prg[end-6:], []byte{0xF0, 0xFF, 0x00, 0x80, 0xF0, 0xFF})
+
+	rom := append(header, prg...)
+	return append(rom, make([]byte, int(chrBanks)*8192)...)
+}
+
+// Bus with a cartridge made by makeROM, after reset.
+func newTestBus(rom []byte) (*Bus, error) {
+	cart, err := NewCartridgeFromBytes(rom)
+	if err != nil {
+		return nil, err
+	}
+	bus := NewBus()
+	bus.InsertCartridge(cart)
+	bus.Reset()
+	return bus, nil
+}
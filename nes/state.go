@@ -0,0 +1,519 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Version Emulator version, recorded in save states.
+const Version = "0.2.0"
+
+// Save state file layout, all little endian:
+//
+//	magic "GNST", format version uint16,
+//	emulator version (uint8 length + string), ROM CRC32 uint32, ROM SHA-1 [20]byte,
+//	chunks of tag [4]byte, chunk version uint16, length uint32, data,
+//	ending with an "END " chunk.
+//
+// Each component owns a chunk and bumps its version when its data changes,
+// registering a StateMigration from the previous version. Chunks a build
+// doesn't know are skipped.
+var stateMagic = [4]byte{'G', 'N', 'S', 'T'}
+
+const stateFormatVersion = 1
+
+const stateEndTag = "END "
+
+// WriteState Write fields of a component in little endian. Each field is a
+// pointer to a fixed-size value, array or struct, or a slice of them. *int is
+// stored as 64-bit.
+func WriteState(w io.Writer, fields ...interface{}) error {
+	for _, field := range fields {
+		var err error
+		if v, ok := field.(*int); ok {
+			err = binary.Write(w, binary.LittleEndian, int64(*v))
+		} else {
+			err = binary.Write(w, binary.LittleEndian, field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadState Read fields written by WriteState back in the same order.
+func ReadState(r io.Reader, fields ...interface{}) error {
+	for _, field := range fields {
+		var err error
+		if v, ok := field.(*int); ok {
+			var value int64
+			err = binary.Read(r, binary.LittleEndian, &value)
+			*v = int(value)
+		} else {
+			err = binary.Read(r, binary.LittleEndian, field)
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Named field of a component, names are shown by InspectState.
+type stateField struct {
+	name  string
+	value interface{}
+}
+
+func stateValues(fields []stateField) []interface{} {
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		values[i] = field.value
+	}
+	return values
+}
+
+// Chunk of a save state. Components described by fields are saved field by
+// field, others bring their own save and load.
+type stateChunk struct {
+	tag     string
+	version uint16
+	fields  func(bus *Bus) []stateField
+	save    func(bus *Bus, w io.Writer) error
+	load    func(bus *Bus, r io.Reader) error
+}
+
+func (chunk *stateChunk) write(bus *Bus, w io.Writer) error {
+	if chunk.fields != nil {
+		return WriteState(w, stateValues(chunk.fields(bus))...)
+	}
+	return chunk.save(bus, w)
+}
+
+func (chunk *stateChunk) read(bus *Bus, r io.Reader) error {
+	if chunk.fields != nil {
+		return ReadState(r, stateValues(chunk.fields(bus))...)
+	}
+	return chunk.load(bus, r)
+}
+
+// Chunks in the order they are saved and loaded.
+var stateChunks = []stateChunk{
+	{tag: "BUS ", version: 1, fields: func(bus *Bus) []stateField { return bus.stateFields() }},
+	{tag: "CPU ", version: 1, fields: func(bus *Bus) []stateField { return bus.CPU.stateFields() }},
+	{tag: "PPU ", version: 1, fields: func(bus *Bus) []stateField { return bus.PPU.stateFields() }},
+	{tag: "APU ", version: 1, fields: func(bus *Bus) []stateField { return bus.APU.stateFields() }},
+	{
+		tag:     "CART",
+		version: 1,
+		save: func(bus *Bus, w io.Writer) error {
+			return WriteState(w, stateValues(bus.cartridge.stateFields())...)
+		},
+		load: func(bus *Bus, r io.Reader) error {
+			return ReadState(r, stateValues(bus.cartridge.stateFields())...)
+		},
+	},
+	{
+		tag:     "MAPR",
+		version: 1,
+		save: func(bus *Bus, w io.Writer) error {
+			return WriteState(w, bus.cartridge.mapper.StateFields()...)
+		},
+		load: func(bus *Bus, r io.Reader) error {
+			return ReadState(r, bus.cartridge.mapper.StateFields()...)
+		},
+	},
+}
+
+// StateMigration Upgrades data of a save state chunk by one version.
+type StateMigration func(data []byte) ([]byte, error)
+
+type stateMigrationKey struct {
+	tag  string
+	from uint16
+}
+
+var (
+	stateMigrationsMutex sync.RWMutex
+	stateMigrations      = map[stateMigrationKey]StateMigration{}
+)
+
+// RegisterStateMigration Register a migration upgrading chunk tag from
+// version from to from+1. Passing nil removes it.
+func RegisterStateMigration(tag string, from uint16, migrate StateMigration) {
+	stateMigrationsMutex.Lock()
+	defer stateMigrationsMutex.Unlock()
+
+	if migrate == nil {
+		delete(stateMigrations, stateMigrationKey{tag, from})
+		return
+	}
+	stateMigrations[stateMigrationKey{tag, from}] = migrate
+}
+
+func lookupStateMigration(tag string, from uint16) (StateMigration, bool) {
+	stateMigrationsMutex.RLock()
+	defer stateMigrationsMutex.RUnlock()
+
+	migrate, ok := stateMigrations[stateMigrationKey{tag, from}]
+	return migrate, ok
+}
+
+// StateHeader Header of a save state file.
+type StateHeader struct {
+	FormatVersion   uint16
+	EmulatorVersion string
+	ROM             ROMHash
+}
+
+// StateChunk Raw chunk of a save state file.
+type StateChunk struct {
+	Tag     string
+	Version uint16
+	Data    []byte
+}
+
+func (bus *Bus) stateFields() []stateField {
+	return []stateField{
+		{"RAM", bus.RAM()},
+		{"Controller", bus.Controller},
+		{"controllerState", bus.controllerState},
+		{"dmaPage", &bus.dmaPage},
+		{"dmaAddr", &bus.dmaAddr},
+		{"dmaData", &bus.dmaData},
+		{"dmaTransfer", &bus.dmaTransfer},
+		{"dmaDummy", &bus.dmaDummy},
+		{"irqLine", &bus.irqLine},
+		{"systemClockCounter", &bus.systemClockCounter},
+	}
+}
+
+func (cpu *CPU) stateFields() []stateField {
+	return []stateField{
+		{"A", &cpu.A},
+		{"X", &cpu.X},
+		{"Y", &cpu.Y},
+		{"SP", &cpu.SP},
+		{"PC", &cpu.PC},
+		{"Status", &cpu.Status},
+		{"fetched", &cpu.fetched},
+		{"temp", &cpu.temp},
+		{"addrAbs", &cpu.addrAbs},
+		{"addrRel", &cpu.addrRel},
+		{"opcode", &cpu.opcode},
+		{"cycles", &cpu.cycles},
+		{"clockCount", &cpu.clockCount},
+		{"halted", &cpu.halted},
+	}
+}
+
+func (ppu *PPU) stateFields() []stateField {
+	return []stateField{
+		{"TableName", &ppu.TableName},
+		{"tablePattern", &ppu.tablePattern},
+		{"tablePalette", &ppu.tablePalette},
+		{"OAM", &ppu.OAM},
+		{"sprite", &ppu.sprite},
+		{"spriteCount", &ppu.spriteCount},
+		{"spriteShifterPatternLo", &ppu.spriteShifterPatternLo},
+		{"spriteShifterPatternHi", &ppu.spriteShifterPatternHi},
+		{"NMI", &ppu.NMI},
+		{"frame", &ppu.frame},
+		{"FrameComplete", &ppu.FrameComplete},
+		{"scanline", &ppu.scanline},
+		{"cycle", &ppu.cycle},
+		{"oddFrame", &ppu.oddFrame},
+		{"status", &ppu.status},
+		{"mask", &ppu.mask},
+		{"control", &ppu.control},
+		{"addressLatch", &ppu.addressLatch},
+		{"ppuDataBuffer", &ppu.ppuDataBuffer},
+		{"vramAddr", &ppu.vramAddr},
+		{"tramAddr", &ppu.tramAddr},
+		{"oamAddr", &ppu.oamAddr},
+		{"fineX", &ppu.fineX},
+		{"nextTileID", &ppu.nextTileID},
+		{"nextTileAttr", &ppu.nextTileAttr},
+		{"nextTileLSB", &ppu.nextTileLSB},
+		{"nextTileMSB", &ppu.nextTileMSB},
+		{"shifterPatternLo", &ppu.shifterPatternLo},
+		{"shifterPatternHi", &ppu.shifterPatternHi},
+		{"shifterAttribLo", &ppu.shifterAttribLo},
+		{"shifterAttribHi", &ppu.shifterAttribHi},
+		{"spriteZeroHitPossible", &ppu.spriteZeroHitPossible},
+		{"spriteZeroBeingRendered", &ppu.spriteZeroBeingRendered},
+	}
+}
+
+func (pulse *apuPulse) stateFields(prefix string) []stateField {
+	return []stateField{
+		{prefix + ".enabled", &pulse.enabled},
+		{prefix + ".dutyMode", &pulse.dutyMode},
+		{prefix + ".dutyValue", &pulse.dutyValue},
+		{prefix + ".lengthHalt", &pulse.lengthHalt},
+		{prefix + ".lengthValue", &pulse.lengthValue},
+		{prefix + ".timerPeriod", &pulse.timerPeriod},
+		{prefix + ".timerValue", &pulse.timerValue},
+		{prefix + ".constantVolume", &pulse.constantVolume},
+		{prefix + ".envelopeStart", &pulse.envelopeStart},
+		{prefix + ".envelopePeriod", &pulse.envelopePeriod},
+		{prefix + ".envelopeValue", &pulse.envelopeValue},
+		{prefix + ".envelopeVolume", &pulse.envelopeVolume},
+		{prefix + ".sweepEnabled", &pulse.sweepEnabled},
+		{prefix + ".sweepReload", &pulse.sweepReload},
+		{prefix + ".sweepNegate", &pulse.sweepNegate},
+		{prefix + ".sweepPeriod", &pulse.sweepPeriod},
+		{prefix + ".sweepValue", &pulse.sweepValue},
+		{prefix + ".sweepShift", &pulse.sweepShift},
+	}
+}
+
+func (triangle *apuTriangle) stateFields() []stateField {
+	return []stateField{
+		{"triangle.enabled", &triangle.enabled},
+		{"triangle.dutyValue", &triangle.dutyValue},
+		{"triangle.lengthHalt", &triangle.lengthHalt},
+		{"triangle.lengthValue", &triangle.lengthValue},
+		{"triangle.timerPeriod", &triangle.timerPeriod},
+		{"triangle.timerValue", &triangle.timerValue},
+		{"triangle.linearReload", &triangle.linearReload},
+		{"triangle.linearPeriod", &triangle.linearPeriod},
+		{"triangle.linearValue", &triangle.linearValue},
+	}
+}
+
+func (noise *apuNoise) stateFields() []stateField {
+	return []stateField{
+		{"noise.enabled", &noise.enabled},
+		{"noise.mode", &noise.mode},
+		{"noise.shiftRegister", &noise.shiftRegister},
+		{"noise.lengthHalt", &noise.lengthHalt},
+		{"noise.lengthValue", &noise.lengthValue},
+		{"noise.timerPeriod", &noise.timerPeriod},
+		{"noise.timerValue", &noise.timerValue},
+		{"noise.constantVolume", &noise.constantVolume},
+		{"noise.envelopeStart", &noise.envelopeStart},
+		{"noise.envelopePeriod", &noise.envelopePeriod},
+		{"noise.envelopeValue", &noise.envelopeValue},
+		{"noise.envelopeVolume", &noise.envelopeVolume},
+	}
+}
+
+func (dmc *apuDMC) stateFields() []stateField {
+	return []stateField{
+		{"dmc.enabled", &dmc.enabled},
+		{"dmc.value", &dmc.value},
+		{"dmc.irqEnabled", &dmc.irqEnabled},
+		{"dmc.irqFlag", &dmc.irqFlag},
+		{"dmc.loop", &dmc.loop},
+		{"dmc.sampleAddress", &dmc.sampleAddress},
+		{"dmc.sampleLength", &dmc.sampleLength},
+		{"dmc.currentAddress", &dmc.currentAddress},
+		{"dmc.currentLength", &dmc.currentLength},
+		{"dmc.shiftRegister", &dmc.shiftRegister},
+		{"dmc.bitCount", &dmc.bitCount},
+		{"dmc.tickPeriod", &dmc.tickPeriod},
+		{"dmc.tickValue", &dmc.tickValue},
+	}
+}
+
+func (apu *APU) stateFields() []stateField {
+	fields := []stateField{
+		{"clockCounter", &apu.clockCounter},
+		{"frameCounter", &apu.frameCounter},
+		{"frameMode", &apu.frameMode},
+		{"frameInhibit", &apu.frameInhibit},
+		{"frameIRQ", &apu.frameIRQ},
+		{"sampleCounter", &apu.sampleCounter},
+	}
+	fields = append(fields, apu.pulse1.stateFields("pulse1")...)
+	fields = append(fields, apu.pulse2.stateFields("pulse2")...)
+	fields = append(fields, apu.triangle.stateFields()...)
+	fields = append(fields, apu.noise.stateFields()...)
+	fields = append(fields, apu.dmc.stateFields()...)
+	return fields
+}
+
+func (cart *Cartridge) stateFields() []stateField {
+	fields := []stateField{
+		{"prgRAM", cart.prgRAM},
+		{"vram", cart.vram},
+	}
+	// CHR ROM never changes.
+	if cart.chrBanks == 0 {
+		fields = append(fields, stateField{"chrRAM", cart.chrMemory})
+	}
+	return fields
+}
+
+// SaveState Write a snapshot of the whole machine.
+func (bus *Bus) SaveState(w io.Writer) error {
+	version := []byte(Version)
+	header := []interface{}{
+		&stateMagic, uint16(stateFormatVersion),
+		uint8(len(version)), version,
+		bus.cartridge.hash.CRC32, &bus.cartridge.hash.SHA1,
+	}
+	if err := WriteState(w, header...); err != nil {
+		return err
+	}
+
+	var data bytes.Buffer
+	for i := range stateChunks {
+		chunk := &stateChunks[i]
+		data.Reset()
+		if err := chunk.write(bus, &data); err != nil {
+			return fmt.Errorf("saving %q chunk: %w", chunk.tag, err)
+		}
+		if err := writeStateChunk(w, chunk.tag, chunk.version, data.Bytes()); err != nil {
+			return err
+		}
+	}
+	return writeStateChunk(w, stateEndTag, 1, nil)
+}
+
+func writeStateChunk(w io.Writer, tag string, version uint16, data []byte) error {
+	var t [4]byte
+	copy(t[:], tag)
+	return WriteState(w, &t, version, uint32(len(data)), data)
+}
+
+// ReadStateFile Read header and raw chunks of a save state, in file order.
+func ReadStateFile(r io.Reader) (StateHeader, []StateChunk, error) {
+	header := StateHeader{}
+
+	var magic [4]byte
+	if err := ReadState(r, &magic); err != nil {
+		return header, nil, fmt.Errorf("%w: %v", ErrNotState, err)
+	}
+	if magic != stateMagic {
+		return header, nil, ErrNotState
+	}
+
+	var versionLength uint8
+	if err := ReadState(r, &header.FormatVersion, &versionLength); err != nil {
+		return header, nil, fmt.Errorf("%w: %v", ErrStateCorrupt, err)
+	}
+	if header.FormatVersion > stateFormatVersion {
+		return header, nil, fmt.Errorf("%w: file format %d is newer than %d", ErrStateVersion, header.FormatVersion, stateFormatVersion)
+	}
+	version := make([]byte, versionLength)
+	if err := ReadState(r, version, &header.ROM.CRC32, &header.ROM.SHA1); err != nil {
+		return header, nil, fmt.Errorf("%w: %v", ErrStateCorrupt, err)
+	}
+	header.EmulatorVersion = string(version)
+
+	var chunks []StateChunk
+	for {
+		var tag [4]byte
+		var chunk StateChunk
+		var length uint32
+		if err := ReadState(r, &tag, &chunk.Version, &length); err != nil {
+			return header, chunks, fmt.Errorf("%w: %v", ErrStateCorrupt, err)
+		}
+		chunk.Tag = string(tag[:])
+		if chunk.Tag == stateEndTag {
+			return header, chunks, nil
+		}
+
+		// Don't trust length with a big allocation, read what is really there.
+		var data bytes.Buffer
+		if n, err := io.CopyN(&data, r, int64(length)); err != nil {
+			return header, chunks, fmt.Errorf("%w: %q chunk has %d of %d bytes", ErrStateCorrupt, chunk.Tag, n, length)
+		}
+		chunk.Data = data.Bytes()
+		chunks = append(chunks, chunk)
+	}
+}
+
+// Bring chunk data up to the version this build writes.
+func migrateStateChunk(chunk StateChunk, version uint16, emulatorVersion string) ([]byte, error) {
+	if chunk.Version > version {
+		return nil, fmt.Errorf("%w: %q chunk version %d from GoNES %s is newer than %d",
+			ErrStateVersion, chunk.Tag, chunk.Version, emulatorVersion, version)
+	}
+
+	data := chunk.Data
+	for v := chunk.Version; v < version; v++ {
+		migrate, ok := lookupStateMigration(chunk.Tag, v)
+		if !ok {
+			return nil, fmt.Errorf("%w: no migration for %q chunk version %d from GoNES %s",
+				ErrStateVersion, chunk.Tag, v, emulatorVersion)
+		}
+		var err error
+		if data, err = migrate(data); err != nil {
+			return nil, fmt.Errorf("migrating %q chunk from version %d: %w", chunk.Tag, v, err)
+		}
+	}
+	return data, nil
+}
+
+// LoadState Restore a snapshot written by SaveState, migrating chunks of
+// older builds. Machine is left as it was if the snapshot can't be loaded.
+func (bus *Bus) LoadState(r io.Reader) error {
+	header, chunks, err := ReadStateFile(r)
+	if err != nil {
+		return err
+	}
+	if header.ROM != bus.cartridge.hash {
+		return fmt.Errorf("%w: saved for ROM %08X, loaded ROM is %08X", ErrStateROM, header.ROM.CRC32, bus.cartridge.hash.CRC32)
+	}
+
+	// Migrate everything first, nothing is touched if one fails.
+	found := map[string]StateChunk{}
+	for _, chunk := range chunks {
+		found[chunk.Tag] = chunk
+	}
+	data := make([][]byte, len(stateChunks))
+	for i, chunk := range stateChunks {
+		raw, ok := found[chunk.tag]
+		if !ok {
+			return fmt.Errorf("%w: missing %q chunk", ErrStateCorrupt, chunk.tag)
+		}
+		if data[i], err = migrateStateChunk(raw, chunk.version, header.EmulatorVersion); err != nil {
+			return err
+		}
+	}
+
+	var backup bytes.Buffer
+	for i := range stateChunks {
+		if err := stateChunks[i].write(bus, &backup); err != nil {
+			return err
+		}
+	}
+
+	for i := range stateChunks {
+		if err = bus.loadStateChunk(&stateChunks[i], data[i]); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		for i := range stateChunks {
+			stateChunks[i].read(bus, &backup)
+		}
+		return err
+	}
+
+	// Audio already queued belongs to the old timeline.
+	bus.APU.samples = bus.APU.samples[:0]
+	bus.cartridge.prgRAMDirty = bus.cartridge.battery
+	return nil
+}
+
+func (bus *Bus) loadStateChunk(chunk *stateChunk, data []byte) error {
+	r := bytes.NewReader(data)
+	if err := chunk.read(bus, r); err != nil {
+		return fmt.Errorf("%w: %q chunk: %v", ErrStateCorrupt, chunk.tag, err)
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%w: %q chunk has %d bytes left over", ErrStateCorrupt, chunk.tag, r.Len())
+	}
+	return nil
+}
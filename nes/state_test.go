@@ -0,0 +1,163 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Bus on an MMC1 board running a loop counting in $10, a few frames in.
+func newStateTestBus(t *testing.T) *Bus {
+	code := []byte{
+		0xE6, 0x10, // INC $10
+		0x4C, 0x00, 0x80, // JMP $8000
+	}
+	bus, err := newTestBus(makeROM(1, 2, 1, 0, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		bus.RunFrame()
+	}
+	return bus
+}
+
+// Save state made of given chunks, in the layout SaveState writes.
+func buildState(t *testing.T, formatVersion uint16, header StateHeader, chunks []StateChunk) []byte {
+	var state bytes.Buffer
+	err := WriteState(&state, &stateMagic, formatVersion,
+		uint8(len(header.EmulatorVersion)), []byte(header.EmulatorVersion),
+		header.ROM.CRC32, &header.ROM.SHA1)
+	for _, chunk := range chunks {
+		if err == nil {
+			err = writeStateChunk(&state, chunk.Tag, chunk.Version, chunk.Data)
+		}
+	}
+	if err == nil {
+		err = writeStateChunk(&state, stateEndTag, 1, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state.Bytes()
+}
+
+func readState(t *testing.T, state []byte) (StateHeader, []StateChunk) {
+	header, chunks, err := ReadStateFile(bytes.NewReader(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header, chunks
+}
+
+// TestStateMigration A chunk saved before its version was bumped goes through
+// the registered migration, chunks this build doesn't know are skipped.
+func TestStateMigration(t *testing.T) {
+	bus := newStateTestBus(t)
+	header, chunks := readState(t, saveStateBytes(t, bus))
+	expected := bus.CPURAM[0x10]
+
+	// Version 2 of BUS chunk would keep RAM byte $10 minus 1.
+	chunk := &stateChunks[0]
+	if chunk.tag != "BUS " {
+		t.Fatalf("first chunk is %q, expected BUS", chunk.tag)
+	}
+	migrated := 0
+	chunk.version = 2
+	defer func() { chunk.version = 1 }()
+	RegisterStateMigration("BUS ", 1, func(data []byte) ([]byte, error) {
+		migrated++
+		data = append([]byte(nil), data...)
+		data[0x10]--
+		return data, nil
+	})
+	defer RegisterStateMigration("BUS ", 1, nil)
+
+	chunks[0].Data[0x10]++
+	chunks = append(chunks, StateChunk{"XTRA", 7, []byte("from a newer build")})
+	state := buildState(t, stateFormatVersion, header, chunks)
+
+	bus.RunFrame()
+	if err := bus.LoadState(bytes.NewReader(state)); err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 1 {
+		t.Errorf("migration ran %d times, expected once", migrated)
+	}
+	if bus.CPURAM[0x10] != expected {
+		t.Errorf("$10 = $%02X after migration, expected $%02X", bus.CPURAM[0x10], expected)
+	}
+
+	// Without the migration, the old chunk can't be loaded.
+	RegisterStateMigration("BUS ", 1, nil)
+	if err := bus.LoadState(bytes.NewReader(state)); !errors.Is(err, ErrStateVersion) {
+		t.Errorf("got %v without migration, expected ErrStateVersion", err)
+	}
+}
+
+// TestStateErrors States that can't be loaded fail with the right error and
+// leave the machine as it was.
+func TestStateErrors(t *testing.T) {
+	bus := newStateTestBus(t)
+	state := saveStateBytes(t, bus)
+	header, chunks := readState(t, state)
+	bus.RunFrame()
+	current := saveStateBytes(t, bus)
+
+	modify := func(change func(chunks []StateChunk) []StateChunk) []byte {
+		copied := make([]StateChunk, len(chunks))
+		for i, chunk := range chunks {
+			copied[i] = StateChunk{chunk.Tag, chunk.Version, append([]byte(nil), chunk.Data...)}
+		}
+		return buildState(t, stateFormatVersion, header, change(copied))
+	}
+	otherROM := header
+	otherROM.ROM.CRC32++
+
+	tests := []struct {
+		name  string
+		state []byte
+		err   error
+	}{
+		{"empty", nil, ErrNotState},
+		{"bad magic", append([]byte("GNSS"), state[4:]...), ErrNotState},
+		{"newer format", buildState(t, stateFormatVersion+1, header, chunks), ErrStateVersion},
+		{"newer chunk", modify(func(chunks []StateChunk) []StateChunk {
+			chunks[1].Version++
+			return chunks
+		}), ErrStateVersion},
+		{"other ROM", buildState(t, stateFormatVersion, otherROM, chunks), ErrStateROM},
+		{"missing chunk", modify(func(chunks []StateChunk) []StateChunk {
+			return chunks[:len(chunks)-1]
+		}), ErrStateCorrupt},
+		{"cut short", state[:len(state)-20], ErrStateCorrupt},
+		// Mapper is loaded last, everything before it is rolled back.
+		{"short mapper chunk", modify(func(chunks []StateChunk) []StateChunk {
+			last := &chunks[len(chunks)-1]
+			last.Data = last.Data[:len(last.Data)-1]
+			return chunks
+		}), ErrStateCorrupt},
+		{"long mapper chunk", modify(func(chunks []StateChunk) []StateChunk {
+			last := &chunks[len(chunks)-1]
+			last.Data = append(last.Data, 0)
+			return chunks
+		}), ErrStateCorrupt},
+	}
+
+	for _, test := range tests {
+		err := bus.LoadState(bytes.NewReader(test.state))
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, expected %v", test.name, err, test.err)
+		}
+		if !bytes.Equal(saveStateBytes(t, bus), current) {
+			t.Fatalf("%s: machine changed by a failed load", test.name)
+		}
+	}
+
+	if err := bus.LoadState(bytes.NewReader(state)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saveStateBytes(t, bus), state) {
+		t.Errorf("state differs after load")
+	}
+}
@@ -0,0 +1,107 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+)
+
+// Bytes shown of chunks without named fields.
+const inspectDumpSize = 64
+
+// InspectState Print header and chunks of a save state. Chunks made of named
+// fields are decoded field by field, others are dumped in hex.
+func InspectState(r io.Reader, w io.Writer) error {
+	header, chunks, err := ReadStateFile(r)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "format %d, saved by GoNES %s\n", header.FormatVersion, header.EmulatorVersion)
+	fmt.Fprintf(w, "ROM CRC32 %08X SHA-1 %X\n", header.ROM.CRC32, header.ROM.SHA1)
+
+	// Known chunks are decoded into a machine of their own.
+	scratch := NewBus()
+
+	for _, raw := range chunks {
+		fmt.Fprintf(w, "\n[%s] version %d, %d bytes\n", raw.Tag, raw.Version, len(raw.Data))
+
+		var chunk *stateChunk
+		for i := range stateChunks {
+			if stateChunks[i].tag == raw.Tag {
+				chunk = &stateChunks[i]
+			}
+		}
+		if chunk == nil {
+			fmt.Fprintf(w, "  unknown to this build, skipped when loading\n")
+			inspectDump(w, raw.Data)
+			continue
+		}
+
+		data, err := migrateStateChunk(raw, chunk.version, header.EmulatorVersion)
+		if err != nil {
+			fmt.Fprintf(w, "  %s\n", err)
+			inspectDump(w, raw.Data)
+			continue
+		}
+		if chunk.fields == nil {
+			inspectDump(w, data)
+			continue
+		}
+
+		fields := chunk.fields(scratch)
+		if err := ReadState(bytes.NewReader(data), stateValues(fields)...); err != nil {
+			fmt.Fprintf(w, "  can't decode: %s\n", err)
+			inspectDump(w, data)
+			continue
+		}
+		for _, field := range fields {
+			fmt.Fprintf(w, "  %-24s %s\n", field.name, formatStateValue(field.value))
+		}
+	}
+	return nil
+}
+
+func inspectDump(w io.Writer, data []byte) {
+	for offset := 0; offset < len(data) && offset < inspectDumpSize; offset += 16 {
+		end := offset + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		fmt.Fprintf(w, "  %04X  % X\n", offset, data[offset:end])
+	}
+	if len(data) > inspectDumpSize {
+		fmt.Fprintf(w, "  ... crc32 %08X\n", crc32.ChecksumIEEE(data))
+	}
+}
+
+// Format a field value, large arrays are summarized by size and checksum.
+func formatStateValue(value interface{}) string {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	switch v.Kind() {
+	case reflect.Bool:
+		return fmt.Sprintf("%t", v.Bool())
+	case reflect.Uint8:
+		return fmt.Sprintf("$%02X", v.Uint())
+	case reflect.Uint16:
+		return fmt.Sprintf("$%04X", v.Uint())
+	case reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d", v.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%d", v.Int())
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%g", v.Float())
+	}
+
+	var data bytes.Buffer
+	if err := binary.Write(&data, binary.LittleEndian, value); err != nil {
+		return fmt.Sprintf("%v", v.Interface())
+	}
+	if data.Len() <= 16 {
+		return fmt.Sprintf("% X", data.Bytes())
+	}
+	return fmt.Sprintf("%d bytes, crc32 %08X", data.Len(), crc32.ChecksumIEEE(data.Bytes()))
+}
@@ -0,0 +1,106 @@
+package nes
+
+// Status codes test ROMs write to $6000, values below 0x80 are final results.
+const (
+	TestStatusRunning      = 0x80
+	TestStatusResetRequest = 0x81
+	TestStatusPassed       = 0x00
+)
+
+const (
+	testStatusAddr    = 0x6000
+	testSignatureAddr = 0x6001
+	testMessageAddr   = 0x6004
+
+	// Reset is pressed a bit after it is requested, ROMs want at least 100ms.
+	testResetDelay = 10
+
+	// ROMs that use the protocol write the signature during start up.
+	testSignatureFrames = 120
+)
+
+var testSignature = [3]uint8{0xDE, 0xB0, 0x61}
+
+// TestROMResult Outcome of running a test ROM.
+type TestROMResult struct {
+	Status     uint8  // Last status read from $6000.
+	Message    string // Text at $6004.
+	Frames     int
+	Resets     int
+	TimedOut   bool
+	NoProtocol bool // Signature never showed up at $6001.
+}
+
+// Passed Check if test ROM reported success.
+func (result TestROMResult) Passed() bool {
+	return !result.TimedOut && !result.NoProtocol && result.Status == TestStatusPassed
+}
+
+// RunTestROM Run a test ROM using the $6000 status protocol until it reports
+// a result or maxFrames elapse. Reset requests are honored.
+func RunTestROM(cart *Cartridge, maxFrames int) TestROMResult {
+	bus := NewBus()
+	bus.InsertCartridge(cart)
+	bus.Reset()
+
+	result := TestROMResult{}
+	signed := false
+	resetAt := -1
+
+	for result.Frames = 0; result.Frames < maxFrames; result.Frames++ {
+		bus.RunFrame()
+
+		if !signed {
+			signed = bus.testSigned()
+			if !signed {
+				if result.Frames >= testSignatureFrames {
+					result.NoProtocol = true
+					return result
+				}
+				continue
+			}
+		}
+
+		result.Status = bus.CPURead(testStatusAddr, true)
+		switch {
+		case result.Status == TestStatusResetRequest:
+			if resetAt < 0 {
+				resetAt = result.Frames + testResetDelay
+			} else if result.Frames >= resetAt {
+				bus.Reset()
+				result.Resets++
+				resetAt = -1
+			}
+		case result.Status < TestStatusRunning:
+			result.Message = bus.testMessage()
+			return result
+		}
+	}
+
+	result.TimedOut = true
+	if signed {
+		result.Message = bus.testMessage()
+	}
+	return result
+}
+
+func (bus *Bus) testSigned() bool {
+	for i, b := range testSignature {
+		if bus.CPURead(testSignatureAddr+uint16(i), true) != b {
+			return false
+		}
+	}
+	return true
+}
+
+func (bus *Bus) testMessage() string {
+	var message []byte
+	for addr := uint16(testMessageAddr); addr < 0x8000; addr++ {
+		c := bus.CPURead(addr, true)
+		if c == 0 {
+			break
+		}
+		message = append(message, c)
+	}
+	return string(message)
+}
@@ -0,0 +1,67 @@
+package nes
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	testROMDir    = flag.String("testrom.dir", "../test", "Directory of test ROMs using the $6000 status protocol")
+	testROMFrames = flag.Int("testrom.frames", 60*60, "Frames to run each test ROM before timing out")
+)
+
+// TestROMs Run every ROM in the test ROM directory and report a pass/fail table.
+// ROMs that don't speak the $6000 protocol are skipped.
+func TestROMs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("test ROMs take emulated minutes to run")
+	}
+
+	files, err := filepath.Glob(filepath.Join(*testROMDir, "*.nes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var table strings.Builder
+	fmt.Fprintf(&table, "%-24s %-6s %6s %6s  %s\n", "ROM", "RESULT", "STATUS", "FRAMES", "MESSAGE")
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		verdict := "SKIP"
+		var result TestROMResult
+
+		t.Run(name, func(t *testing.T) {
+			cart, err := NewCartridge(file)
+			if err != nil {
+				verdict = "ERROR"
+				t.Fatalf("loading %s: %v", file, err)
+			}
+
+			result = RunTestROM(cart, *testROMFrames)
+			switch {
+			case result.NoProtocol:
+				t.Skip("no $6000 status protocol")
+			case result.Passed():
+				verdict = "PASS"
+			case result.TimedOut:
+				verdict = "FAIL"
+				t.Errorf("timed out after %d frames: %s", result.Frames, result.Message)
+			default:
+				verdict = "FAIL"
+				t.Errorf("status $%02X: %s", result.Status, result.Message)
+			}
+		})
+
+		status := "-"
+		if !result.NoProtocol {
+			status = fmt.Sprintf("$%02X", result.Status)
+		}
+		message := strings.Join(strings.Fields(result.Message), " ")
+		fmt.Fprintf(&table, "%-24s %-6s %6s %6d  %s\n", name, verdict, status, result.Frames, message)
+	}
+
+	t.Logf("test ROM results:\n%s", table.String())
+}
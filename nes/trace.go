@@ -0,0 +1,137 @@
+package nes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Trace Format the instruction at PC together with CPU and PPU state as
+// one line of a nestest.log style trace. Memory is peeked without side effects.
+func (bus *Bus) Trace() string {
+	cpu := bus.CPU
+	peek := func(addr uint16) uint8 {
+		return bus.CPURead(addr, true)
+	}
+	peekWord := func(lo uint16, hi uint16) uint16 {
+		return uint16(peek(hi))<<8 | uint16(peek(lo))
+	}
+
+	pc := cpu.PC
+	opcode := peek(pc)
+	name := instructionNames[opcode]
+	prefix := " "
+	if name[0] == '*' {
+		prefix = "*"
+		name = name[1:]
+	}
+
+	mode := instructionModes[opcode]
+	length := 1
+	switch mode {
+	case modeImmediate, modeZeroPage, modeZeroPageX, modeZeroPageY, modeIndirectX, modeIndirectY, modeRelative:
+		length = 2
+	case modeAbsolute, modeAbsoluteX, modeAbsoluteY, modeIndirect:
+		length = 3
+	}
+
+	bytes := ""
+	for i := 0; i < length; i++ {
+		if i > 0 {
+			bytes += " "
+		}
+		bytes += fmt.Sprintf("%02X", peek(pc+uint16(i)))
+	}
+
+	op8 := peek(pc + 1)
+	op16 := peekWord(pc+1, pc+2)
+
+	operand := ""
+	switch mode {
+	case modeImplied:
+		// Shifts and rotates on the accumulator.
+		switch opcode {
+		case 0x0A, 0x2A, 0x4A, 0x6A:
+			operand = "A"
+		}
+	case modeImmediate:
+		operand = fmt.Sprintf("#$%02X", op8)
+	case modeZeroPage:
+		operand = fmt.Sprintf("$%02X = %02X", op8, peek(uint16(op8)))
+	case modeZeroPageX:
+		addr := op8 + cpu.X
+		operand = fmt.Sprintf("$%02X,X @ %02X = %02X", op8, addr, peek(uint16(addr)))
+	case modeZeroPageY:
+		addr := op8 + cpu.Y
+		operand = fmt.Sprintf("$%02X,Y @ %02X = %02X", op8, addr, peek(uint16(addr)))
+	case modeIndirectX:
+		ptr := op8 + cpu.X
+		addr := peekWord(uint16(ptr), uint16(ptr+1))
+		operand = fmt.Sprintf("($%02X,X) @ %02X = %04X = %02X", op8, ptr, addr, peek(addr))
+	case modeIndirectY:
+		base := peekWord(uint16(op8), uint16(op8+1))
+		addr := base + uint16(cpu.Y)
+		operand = fmt.Sprintf("($%02X),Y = %04X @ %04X = %02X", op8, base, addr, peek(addr))
+	case modeAbsolute:
+		if opcode == 0x4C || opcode == 0x20 {
+			operand = fmt.Sprintf("$%04X", op16)
+		} else {
+			operand = fmt.Sprintf("$%04X = %02X", op16, peek(op16))
+		}
+	case modeAbsoluteX:
+		addr := op16 + uint16(cpu.X)
+		operand = fmt.Sprintf("$%04X,X @ %04X = %02X", op16, addr, peek(addr))
+	case modeAbsoluteY:
+		addr := op16 + uint16(cpu.Y)
+		operand = fmt.Sprintf("$%04X,Y @ %04X = %02X", op16, addr, peek(addr))
+	case modeIndirect:
+		// Emulate page boundary hardware bug.
+		addr := peekWord(op16, (op16&0xFF00)|((op16+1)&0x00FF))
+		operand = fmt.Sprintf("($%04X) = %04X", op16, addr)
+	case modeRelative:
+		operand = fmt.Sprintf("$%04X", pc+2+uint16(int8(op8)))
+	}
+
+	asm := prefix + name
+	if operand != "" {
+		asm += " " + operand
+	}
+
+	// Pre-render scanline is numbered 261 in logs.
+	scanline := bus.PPU.scanline
+	if scanline < 0 {
+		scanline = 261
+	}
+
+	return fmt.Sprintf("%04X  %-9s%-33sA:%02X X:%02X Y:%02X P:%02X SP:%02X PPU:%3d,%3d CYC:%d",
+		pc, bytes, asm, cpu.A, cpu.X, cpu.Y, cpu.Status, cpu.SP, scanline, bus.PPU.cycle, cpu.clockCount)
+}
+
+// StepInstruction Clock the system until the CPU is about to fetch its next
+// instruction. Returns false if the CPU is jammed and never will.
+func (bus *Bus) StepInstruction() bool {
+	for {
+		if bus.CPU.Halted() {
+			return false
+		}
+		bus.Clock()
+		if bus.systemClockCounter%3 == 0 && bus.CPU.Complete() && !bus.dmaTransfer {
+			return true
+		}
+	}
+}
+
+// RunTrace Write a trace line for each of the next count instructions,
+// stopping early if the CPU jams.
+func (bus *Bus) RunTrace(w io.Writer, count int) error {
+	out := bufio.NewWriter(w)
+	for i := 0; i < count; i++ {
+		if _, err := fmt.Fprintln(out, bus.Trace()); err != nil {
+			return err
+		}
+		if !bus.StepInstruction() {
+			break
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,308 @@
+package nes
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// MachineHash SHA-1 of the parts of a machine compared between runs.
+type MachineHash struct {
+	CPU   [sha1.Size]byte // Registers and cycle counts.
+	RAM   [sha1.Size]byte // Internal and cartridge work RAM.
+	VRAM  [sha1.Size]byte // Name tables, palette, OAM and CHR RAM.
+	Frame [sha1.Size]byte // Framebuffer palette indices.
+}
+
+// Names of MachineHash parts, as used by String and Diff.
+var machineHashParts = []string{"cpu", "ram", "vram", "frame"}
+
+// Hash Hash the machine as it is now.
+func (bus *Bus) Hash() MachineHash {
+	hash := MachineHash{}
+	hash.CPU = hashState(bus.CPU.stateFields())
+	hash.RAM = hashState([]stateField{
+		{"RAM", bus.RAM()},
+		{"prgRAM", bus.cartridge.prgRAM},
+	})
+	vram := []stateField{
+		{"TableName", &bus.PPU.TableName},
+		{"tablePalette", &bus.PPU.tablePalette},
+		{"OAM", &bus.PPU.OAM},
+		{"vram", bus.cartridge.vram},
+	}
+	if bus.cartridge.chrBanks == 0 {
+		vram = append(vram, stateField{"chrRAM", bus.cartridge.chrMemory})
+	}
+	hash.VRAM = hashState(vram)
+	hash.Frame = sha1.Sum(bus.PPU.GetFrame())
+	return hash
+}
+
+func hashState(fields []stateField) [sha1.Size]byte {
+	var sum [sha1.Size]byte
+	h := sha1.New()
+	WriteState(h, stateValues(fields)...)
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func (hash *MachineHash) parts() [][sha1.Size]byte {
+	return [][sha1.Size]byte{hash.CPU, hash.RAM, hash.VRAM, hash.Frame}
+}
+
+// Diff Names of the parts that differ from other, empty if none do.
+func (hash MachineHash) Diff(other MachineHash) []string {
+	var names []string
+	mine, theirs := hash.parts(), other.parts()
+	for i := range mine {
+		if mine[i] != theirs[i] {
+			names = append(names, machineHashParts[i])
+		}
+	}
+	return names
+}
+
+// String Format as "cpu=... ram=... vram=... frame=..." in hex.
+func (hash MachineHash) String() string {
+	fields := make([]string, len(machineHashParts))
+	for i, sum := range hash.parts() {
+		fields[i] = machineHashParts[i] + "=" + hex.EncodeToString(sum[:])
+	}
+	return strings.Join(fields, " ")
+}
+
+// ParseMachineHash Parse a hash formatted by String.
+func ParseMachineHash(s string) (MachineHash, error) {
+	hash := MachineHash{}
+	targets := []*[sha1.Size]byte{&hash.CPU, &hash.RAM, &hash.VRAM, &hash.Frame}
+	fields := strings.Fields(s)
+	if len(fields) != len(targets) {
+		return hash, fmt.Errorf("bad machine hash %q", s)
+	}
+	for i, field := range fields {
+		prefix := machineHashParts[i] + "="
+		sum, err := hex.DecodeString(strings.TrimPrefix(field, prefix))
+		if !strings.HasPrefix(field, prefix) || err != nil || len(sum) != sha1.Size {
+			return hash, fmt.Errorf("bad %s hash %q", machineHashParts[i], field)
+		}
+		copy(targets[i][:], sum)
+	}
+	return hash, nil
+}
+
+// VerifyOptions How VerifyMovie runs a movie.
+type VerifyOptions struct {
+	// Frames to run, 0 or less for the whole movie.
+	Frames int
+
+	// Reload the second run from its own save state every frame, so state
+	// missing from save states shows up as a divergence.
+	RoundTrip bool
+
+	// Called with the hash of the first run after each frame, may be nil.
+	OnFrame func(frame int, hash MachineHash)
+}
+
+// Divergence Where two runs of a movie first differ.
+type Divergence struct {
+	Frame int      // Movie frame the runs differ after.
+	Parts []string // Parts of the machine differing after the frame.
+
+	// Instructions into the frame after which the runs differ, 0 if they
+	// already differ when it starts, -1 if no instruction boundary does.
+	Instruction int
+
+	// Trace line of the instruction each run executed last, empty if none.
+	Trace [2]string
+
+	// Both machines right after they started to differ.
+	Runs [2]*Bus
+}
+
+// VerifyMovie Play movie twice side by side from power-on, comparing machine
+// hashes after each frame. When they differ, the frame is replayed from
+// snapshots and bisected to the first instruction leaving the machines
+// different. Returns nil if the runs never differ. Each run and replay gets
+// its own clone of cart, which itself is left untouched.
+func VerifyMovie(cart *Cartridge, movie *Movie, options VerifyOptions) (*Divergence, error) {
+	frames := len(movie.Frames)
+	if options.Frames > 0 && options.Frames < frames {
+		frames = options.Frames
+	}
+
+	var runs [2]*Bus
+	var players [2]*MoviePlayer
+	for i := range runs {
+		clone, err := cart.Clone()
+		if err != nil {
+			return nil, err
+		}
+		runs[i] = NewBus()
+		runs[i].InsertCartridge(clone)
+		runs[i].Reset()
+		if players[i], err = NewMoviePlayer(runs[i], movie); err != nil {
+			return nil, err
+		}
+	}
+
+	var snapshots [2][]byte
+	for frame := 0; frame < frames; frame++ {
+		for i, bus := range runs {
+			var state bytes.Buffer
+			if err := bus.SaveState(&state); err != nil {
+				return nil, err
+			}
+			snapshots[i] = state.Bytes()
+		}
+		if options.RoundTrip {
+			if err := runs[1].LoadState(bytes.NewReader(snapshots[1])); err != nil {
+				return nil, err
+			}
+		}
+
+		for i, bus := range runs {
+			if _, err := players[i].Frame(); err != nil {
+				return nil, fmt.Errorf("frame %d: %w", frame, err)
+			}
+			bus.RunFrame()
+		}
+
+		hash := runs[0].Hash()
+		if options.OnFrame != nil {
+			options.OnFrame(frame, hash)
+		}
+		if parts := hash.Diff(runs[1].Hash()); len(parts) > 0 {
+			divergence := Divergence{Frame: frame, Parts: parts}
+			return &divergence, divergence.bisect(cart, snapshots, movie.Frames[frame])
+		}
+	}
+	return nil, nil
+}
+
+// Machine of a run, restored to the start of the diverging frame and
+// stepped count instructions into it.
+func (divergence *Divergence) replay(cart *Cartridge, snapshot []byte, input MovieFrame, count int) (*Bus, string, error) {
+	clone, err := cart.Clone()
+	if err != nil {
+		return nil, "", err
+	}
+	bus := NewBus()
+	bus.InsertCartridge(clone)
+	if err := bus.LoadState(bytes.NewReader(snapshot)); err != nil {
+		return nil, "", err
+	}
+	if err := input.Apply(bus); err != nil {
+		return nil, "", err
+	}
+
+	trace := ""
+	for i := 0; i < count; i++ {
+		trace = bus.Trace()
+		if !bus.StepInstruction() {
+			break
+		}
+	}
+	return bus, trace, nil
+}
+
+func (divergence *Divergence) bisect(cart *Cartridge, snapshots [2][]byte, input MovieFrame) error {
+	differ := func(count int) (bool, error) {
+		for i := range snapshots {
+			var err error
+			if divergence.Runs[i], divergence.Trace[i], err = divergence.replay(cart, snapshots[i], input, count); err != nil {
+				return false, err
+			}
+		}
+		return divergence.Runs[0].Hash() != divergence.Runs[1].Hash(), nil
+	}
+
+	// Instructions the first run gets through in the frame.
+	bus, _, err := divergence.replay(cart, snapshots[0], input, 0)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for !bus.PPU.FrameComplete && bus.StepInstruction() {
+		total++
+	}
+
+	// Runs that differ once stay different, so a binary search over the
+	// instruction count finds the first one that differs.
+	divergence.Instruction = -1
+	if ok, err := differ(total + 1); err != nil || !ok {
+		return err
+	}
+	lo, hi := -1, total+1
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		ok, err := differ(mid)
+		if err != nil {
+			return err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	divergence.Instruction = hi
+	_, err = differ(hi)
+	return err
+}
+
+// Report Print where the runs differ and both machines side by side.
+func (divergence *Divergence) Report(w io.Writer) error {
+	fmt.Fprintf(w, "runs differ after frame %d in %s\n", divergence.Frame, strings.Join(divergence.Parts, ", "))
+	switch {
+	case divergence.Instruction < 0:
+		fmt.Fprintf(w, "no instruction boundary in the frame differs, replaying from snapshots may not reproduce it\n")
+	case divergence.Instruction == 0:
+		fmt.Fprintf(w, "runs already differ when the frame starts\n")
+	default:
+		fmt.Fprintf(w, "first differing instruction is %d of the frame\n", divergence.Instruction)
+		fmt.Fprintf(w, "  run 1: %s\n", divergence.Trace[0])
+		fmt.Fprintf(w, "  run 2: %s\n", divergence.Trace[1])
+	}
+	if divergence.Runs[0] == nil || divergence.Runs[1] == nil {
+		return nil
+	}
+
+	fmt.Fprintf(w, "\n  %-32s %-36s %s\n", "", "run 1", "run 2")
+	for i := range stateChunks {
+		chunk := &stateChunks[i]
+		if chunk.fields == nil {
+			var data [2]bytes.Buffer
+			for run := range data {
+				if err := chunk.save(divergence.Runs[run], &data[run]); err != nil {
+					return err
+				}
+			}
+			inspectRow(w, chunk.tag, "data", inspectChunkData(data[0].Bytes()), inspectChunkData(data[1].Bytes()))
+			continue
+		}
+
+		fields := [2][]stateField{chunk.fields(divergence.Runs[0]), chunk.fields(divergence.Runs[1])}
+		for j := range fields[0] {
+			inspectRow(w, chunk.tag, fields[0][j].name, formatStateValue(fields[0][j].value), formatStateValue(fields[1][j].value))
+		}
+	}
+	return nil
+}
+
+func inspectChunkData(data []byte) string {
+	return fmt.Sprintf("%d bytes, crc32 %08X", len(data), crc32.ChecksumIEEE(data))
+}
+
+// One line of a side by side listing, differing values are marked with *.
+func inspectRow(w io.Writer, tag string, name string, a string, b string) {
+	mark := " "
+	if a != b {
+		mark = "*"
+	}
+	fmt.Fprintf(w, "%s %s %-27s %-36s %s\n", mark, tag, name, a, b)
+}
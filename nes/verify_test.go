@@ -0,0 +1,194 @@
+package nes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestVerifyMovie Runs of a game writing cartridge RAM don't disturb each
+// other, a deterministic movie verifies clean, also with save state round
+// trips, and cartridge given is left as it was.
+func TestVerifyMovie(t *testing.T) {
+	code := []byte{
+		0xEE, 0x00, 0x60, // INC $6000
+		0xAD, 0x00, 0x60, 0x85, 0x00, // LDA $6000, STA $00
+		0xAD, 0x16, 0x40, 0x85, 0x01, // LDA $4016, STA $01
+		0x4C, 0x00, 0x80, // JMP $8000
+	}
+	cart, err := NewCartridgeFromBytes(makeROM(0, 1, 1, 0, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prgRAM := append([]uint8(nil), cart.prgRAM...)
+
+	movie := NewMovie(cart, nil)
+	for frame := 0; frame < 30; frame++ {
+		movie.Frames = append(movie.Frames, MovieFrame{Pads: [2]uint8{uint8(frame) & ButtonStart}})
+	}
+
+	for _, roundTrip := range []bool{false, true} {
+		frames := 0
+		divergence, err := VerifyMovie(cart, movie, VerifyOptions{
+			RoundTrip: roundTrip,
+			OnFrame:   func(frame int, hash MachineHash) { frames++ },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if divergence != nil {
+			var report bytes.Buffer
+			divergence.Report(&report)
+			t.Fatalf("round trip %t: runs diverge\n%s", roundTrip, report.String())
+		}
+		if frames != len(movie.Frames) {
+			t.Errorf("round trip %t: %d frames verified, expected %d", roundTrip, frames, len(movie.Frames))
+		}
+	}
+
+	if !bytes.Equal(cart.prgRAM, prgRAM) {
+		t.Errorf("verifying changed PRG RAM of the cartridge")
+	}
+}
+
+// Mapper 0 with $6000-$7FFF shifted by offset, so a test can make one run
+// go its own way.
+type offsetRAMMapper struct {
+	Mapper
+	offset uint8
+}
+
+func (mapper *offsetRAMMapper) CPUMapRead(addr uint16, mappedAddr *uint32) bool {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		*mappedAddr = uint32(addr+uint16(mapper.offset)) & 0x1FFF
+		return true
+	}
+	return mapper.Mapper.CPUMapRead(addr, mappedAddr)
+}
+
+func (mapper *offsetRAMMapper) CPUMapWrite(addr uint16, mappedAddr *uint32, data uint8) bool {
+	if addr >= 0x6000 && addr <= 0x7FFF {
+		*mappedAddr = uint32(addr+uint16(mapper.offset)) & 0x1FFF
+		return true
+	}
+	return mapper.Mapper.CPUMapWrite(addr, mappedAddr, data)
+}
+
+func (mapper *offsetRAMMapper) StateFields() []interface{} {
+	return append(mapper.Mapper.StateFields(), &mapper.offset)
+}
+
+// TestVerifyMovieDivergence Runs made to differ are bisected to the first
+// instruction leaving them different, and the report marks what differs.
+func TestVerifyMovieDivergence(t *testing.T) {
+	const id = 0x1F2
+	var mappers []*offsetRAMMapper
+	RegisterMapper(id, 0, func(info CartridgeInfo, prgBanks uint8, chrBanks uint8) Mapper {
+		mapper := &offsetRAMMapper{Mapper: NewMapper0(prgBanks, chrBanks)}
+		mappers = append(mappers, mapper)
+		return mapper
+	})
+	defer RegisterMapper(id, 0, nil)
+
+	code := []byte{
+		0xEE, 0x00, 0x60, // INC $6000
+		0x4C, 0x00, 0x80, // JMP $8000
+	}
+	rom := makeNES20ROM(id, 0)
+	copy(rom[16:], code)
+	cart, err := NewCartridgeFromBytes(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	movie := NewMovie(cart, nil)
+	movie.Frames = make([]MovieFrame, 30)
+
+	// Second run writes another byte of PRG RAM from frame 11 on.
+	const diverge = 11
+	mappers = nil
+	divergence, err := VerifyMovie(cart, movie, VerifyOptions{
+		OnFrame: func(frame int, hash MachineHash) {
+			if frame == diverge-1 {
+				mappers[1].offset = 1
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if divergence == nil {
+		t.Fatal("runs never diverged")
+	}
+
+	if divergence.Frame != diverge {
+		t.Errorf("diverged after frame %d, expected %d", divergence.Frame, diverge)
+	}
+	parts := strings.Join(divergence.Parts, " ")
+	if !strings.Contains(parts, "ram") || strings.Contains(parts, "vram") || strings.Contains(parts, "frame") {
+		t.Errorf("got parts %v, expected ram without vram and frame", divergence.Parts)
+	}
+	// Loop is two instructions and the frame may start in the middle of one,
+	// the first INC of the frame makes the runs differ.
+	if divergence.Instruction < 1 || divergence.Instruction > 3 {
+		t.Errorf("first differing instruction %d, expected one of the first 3", divergence.Instruction)
+	}
+	for run, trace := range divergence.Trace {
+		if !strings.Contains(trace, "INC $6000") {
+			t.Errorf("run %d last executed %q, expected INC $6000", run+1, trace)
+		}
+	}
+	if divergence.Runs[0].cartridge.prgRAM[0] == 0 || divergence.Runs[1].cartridge.prgRAM[1] != 1 {
+		t.Errorf("replayed runs don't show the INC of each")
+	}
+
+	var report bytes.Buffer
+	if err := divergence.Report(&report); err != nil {
+		t.Fatal(err)
+	}
+	marked := map[string]bool{}
+	for _, line := range strings.Split(report.String(), "\n") {
+		fields := strings.Fields(line)
+		if strings.HasPrefix(line, "* ") && len(fields) > 2 {
+			marked[fields[1]+" "+fields[2]] = true
+		}
+	}
+	for _, row := range []string{"CART data", "MAPR data"} {
+		if !marked[row] {
+			t.Errorf("%s not marked in report\n%s", row, report.String())
+		}
+	}
+	for row := range marked {
+		if strings.HasPrefix(row, "PPU ") {
+			t.Errorf("%s marked, expected PPU rows the same\n%s", row, report.String())
+		}
+	}
+}
+
+// TestCartridgeClone Clone starts in the same state and then runs on its own.
+func TestCartridgeClone(t *testing.T) {
+	bus, err := newTestBus(makeROM(1, 2, 0, 0, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cart := bus.cartridge
+	mapper := cart.mapper.(*Mapper1)
+	mapper.chrBank0, mapper.prgBank = 3, 1
+	cart.prgRAM[0x10], cart.chrMemory[0x20] = 0xAB, 0xCD
+
+	clone, err := cart.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloned := clone.mapper.(*Mapper1)
+	if cloned == mapper || cloned.chrBank0 != 3 || cloned.prgBank != 1 {
+		t.Errorf("mapper registers not copied")
+	}
+	if clone.prgRAM[0x10] != 0xAB || clone.chrMemory[0x20] != 0xCD {
+		t.Errorf("cartridge memory not copied")
+	}
+
+	clone.prgRAM[0x10], clone.chrMemory[0x20], cloned.prgBank = 0, 0, 0
+	if cart.prgRAM[0x10] != 0xAB || cart.chrMemory[0x20] != 0xCD || mapper.prgBank != 1 {
+		t.Errorf("clone shares state with the original")
+	}
+}
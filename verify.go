@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/net2cn/GoNES/nes"
+)
+
+// verifyOptions What a verify run checks.
+type verifyOptions struct {
+	movie      string
+	frames     int    // 0 or less runs the whole movie.
+	roundTrip  bool   // Reload second run from save states every frame.
+	hashes     string // Write hash log of this build.
+	against    string // Compare with hash log of another build.
+	traceFrame int    // Frame logged instruction by instruction, -1 for none.
+}
+
+// runVerify Check that a movie plays the same twice in this build, or the
+// same as in the build that wrote a hash log. Returns an error if it doesn't.
+func runVerify(file string, patches []string, options verifyOptions) error {
+	if options.movie == "" {
+		return fmt.Errorf("verify needs a -movie")
+	}
+	cart, err := nes.NewCartridge(file, patches...)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(options.movie)
+	if err != nil {
+		return err
+	}
+	movie, err := nes.ReadMovie(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := movie.CheckROM(cart); err != nil {
+		fmt.Printf("Warning: %s\n", err)
+	}
+
+	frames := len(movie.Frames)
+	if options.frames > 0 && options.frames < frames {
+		frames = options.frames
+	}
+
+	if options.hashes != "" {
+		return writeHashLog(cart, movie, frames, options)
+	}
+	if options.against != "" {
+		return compareHashLog(cart, movie, frames, options)
+	}
+
+	var last nes.MachineHash
+	divergence, err := nes.VerifyMovie(cart, movie, nes.VerifyOptions{
+		Frames:    frames,
+		RoundTrip: options.roundTrip,
+		OnFrame:   func(frame int, hash nes.MachineHash) { last = hash },
+	})
+	if err != nil {
+		return err
+	}
+	if divergence != nil {
+		if err := divergence.Report(os.Stdout); err != nil {
+			return err
+		}
+		return fmt.Errorf("runs are not deterministic")
+	}
+	fmt.Printf("frames %d match, %s\n", frames, last)
+	return nil
+}
+
+// Hash log lines of a single run, one per frame, and one per instruction of
+// the trace frame:
+//
+//	frame N cpu=... ram=... vram=... frame=...
+//	step N I cpu=... ram=... vram=... frame=... | trace line
+func hashLog(cart *nes.Cartridge, movie *nes.Movie, frames int, traceFrame int, emit func(line string) error) error {
+	bus := nes.NewBus()
+	bus.InsertCartridge(cart)
+	bus.Reset()
+	player, err := nes.NewMoviePlayer(bus, movie)
+	if err != nil {
+		return err
+	}
+
+	for frame := 0; frame < frames; frame++ {
+		if _, err := player.Frame(); err != nil {
+			return err
+		}
+
+		if frame == traceFrame {
+			for step := 0; !bus.PPU.FrameComplete; step++ {
+				trace := bus.Trace()
+				if !bus.StepInstruction() {
+					break
+				}
+				if err := emit(fmt.Sprintf("step %d %d %s | %s", frame, step, bus.Hash(), trace)); err != nil {
+					return err
+				}
+			}
+		}
+		bus.RunFrame()
+
+		if err := emit(fmt.Sprintf("frame %d %s", frame, bus.Hash())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHashLog(cart *nes.Cartridge, movie *nes.Movie, frames int, options verifyOptions) error {
+	f, err := os.Create(options.hashes)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = hashLog(cart, movie, frames, options.traceFrame, func(line string) error {
+		_, err := fmt.Fprintln(w, line)
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// errLogDiffers Stops hashLog at the first line that differs.
+var errLogDiffers = errors.New("hash logs differ")
+
+func compareHashLog(cart *nes.Cartridge, movie *nes.Movie, frames int, options verifyOptions) error {
+	f, err := os.Open(options.against)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+
+	lines := 0
+	err = hashLog(cart, movie, frames, options.traceFrame, func(line string) error {
+		expected := ""
+		if scanner.Scan() {
+			expected = scanner.Text()
+		}
+		lines++
+		if line == expected {
+			return nil
+		}
+
+		fmt.Printf("hash logs differ at line %d\n  other: %s\n  this:  %s\n", lines, expected, line)
+		otherFields, thisFields := strings.Fields(expected), strings.Fields(line)
+		if len(otherFields) == 6 && otherFields[0] == "frame" && thisFields[0] == "frame" {
+			other, err := nes.ParseMachineHash(strings.Join(otherFields[2:], " "))
+			if err != nil {
+				return err
+			}
+			this, _ := nes.ParseMachineHash(strings.Join(thisFields[2:], " "))
+			fmt.Printf("differing parts: %s\n", strings.Join(this.Diff(other), ", "))
+			fmt.Printf("log both builds with -trace-frame %s to find the first differing instruction\n", otherFields[1])
+		}
+		return errLogDiffers
+	})
+	if err != nil {
+		return err
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if scanner.Scan() {
+		fmt.Printf("hash logs differ at line %d\n  other: %s\n  this:  end of log\n", lines+1, scanner.Text())
+		return errLogDiffers
+	}
+	fmt.Printf("%d lines match %s\n", lines, options.against)
+	return nil
+}